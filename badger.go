@@ -1,20 +1,38 @@
 package cache
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
-
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/dgraph-io/badger/v3/options"
 	"github.com/platship/go-utils/osx"
 	"github.com/platship/go-utils/timex"
+	"gopkg.in/ini.v1"
+)
+
+// User meta bytes telling string values, hashes and hash fields apart.
+// A hash is stored as a marker entry under its key and one entry per field
+// under key + badgerHashSep + field, all sharing the same expiration.
+// badgerMetaString marks raw bytes written before values were encoded,
+// badgerMetaValue a value encoded by the codec, encodeValue by default.
+const (
+	badgerMetaString byte = iota
+	badgerMetaHash
+	badgerMetaHashField
+	badgerMetaValue
 )
 
+const badgerHashSep = "\x00"
+
 type BadgerCache struct {
 	Path string `json:"path"`
 
@@ -31,81 +49,242 @@ type BadgerCache struct {
 	SyncWrites       bool                    `json:"syncWrites"`       // 同步写 关闭可以提高性能
 	GcInterval       timex.Duration          `json:"gcInterval"`       // 垃圾回收时间间隔
 	GcDiscardRatio   float64                 `json:"gcDiscardRatio"`   // 垃圾回收丢弃比例
+	EncryptionKey    []byte                  `json:"-"`                // 数据加密密钥 16、24或32字节
+	IndexCacheSize   int64                   `json:"indexCacheSize"`   // 索引缓存大小（兆），加密时必须大于0
 
-	Handle *badger.DB `json:"-"`
-	onceGC sync.Once
+	Handle *badger.DB   `json:"-"`
+	lock   sync.RWMutex // held for reading by operations and for writing by Close
+	closed atomic.Bool
+	gc     *gcLoop
+	codec  Codec        // encodes the values when set, otherwise encodeValue does
+	fields *fieldCipher // encrypts the values of hash fields when set
 	prefix string
 }
 
-// Set puts value into cache with key and expire time.
-// If expired is 0, it lives forever.
-func (b *BadgerCache) Set(key string, val interface{}, ttl int64) error {
-	if err := b.undefined(); err != nil {
+// NewBadgerCache creates and returns a new badger cacher with default tuning.
+func NewBadgerCache() *BadgerCache {
+	return &BadgerCache{
+		Path:             "/badger",
+		NumMemtables:     2,
+		MaxTableSize:     16,
+		ValueLogFileSize: 256, // 设置512在1G内存下会占用过高
+		NumCompactors:    2,
+		Compression:      options.Snappy,
+		SyncWrites:       false,
+		GcInterval:       timex.Duration{Number: 5, Unit: timex.DurationMinute},
+		GcDiscardRatio:   0.9,
+	}
+}
+
+// SetCtx puts value into cache with key and expire time in seconds.
+// If expired is 0, it lives forever. Values of any type are accepted and
+// keep their kind, see Get for the shape they are returned in.
+func (b *BadgerCache) SetCtx(ctx context.Context, key string, val interface{}, ttl int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.SetExCtx(ctx, key, val, time.Duration(ttl)*time.Second)
+}
+
+// SetExCtx puts value into cache with key and expire duration,
+// rounded up to whole seconds.
+func (b *BadgerCache) SetExCtx(ctx context.Context, key string, val interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.acquire(); err != nil {
 		return err
 	}
-	return b.Handle.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry([]byte(b.prefix+key), val.([]byte))
-		if ttl > 0 {
-			e.WithTTL(time.Duration(ttl))
+	defer b.release()
+	data, err := b.encode(val)
+	if err != nil {
+		return err
+	}
+	return b.update(ctx, func(txn *badger.Txn) error {
+		if err := b.delete(txn, key); err != nil {
+			return err
 		}
+		e := badger.NewEntry(b.key(key), data).WithMeta(badgerMetaValue)
+		e.ExpiresAt = badgerExpiresAt(ttl)
 		return txn.SetEntry(e)
 	})
 }
 
-// Get gets cached value by given key.
-func (b *BadgerCache) Get(key string) (res interface{}, err error) {
-	if err := b.undefined(); err != nil {
+// GetCtx gets cached value by given key.
+// Like the file and redis adapters, scalars are returned as strings and
+// other values as their decoded JSON, byte slices are returned as is.
+func (b *BadgerCache) GetCtx(ctx context.Context, key string) (res interface{}, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := b.acquire(); err != nil {
 		return nil, err
 	}
+	defer b.release()
 	err = b.Handle.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(b.prefix + key))
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		var vv interface{} = val
-		res = vv
+		res, _, err = b.value(txn, key)
 		return err
 	})
-	return
+	if err != nil {
+		return nil, err
+	}
+	if b.codec == nil && !isNotNumber(res) {
+		res = ToStr(res)
+	}
+	return res, nil
 }
 
-// Delete deletes cached value by given key.
-func (b *BadgerCache) Del(key string) error {
-	if err := b.undefined(); err != nil {
-		return err
+// encode encodes val with the codec, or encodeValue when there is none.
+func (b *BadgerCache) encode(val interface{}) ([]byte, error) {
+	if b.codec != nil {
+		return b.codec.Marshal(val)
 	}
-	return b.Handle.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(b.prefix + key))
-	})
+	return encodeValue(val)
 }
 
-// Incr increases cached int-type value by given key as a counter.
-func (c *BadgerCache) Incr(key string) error {
+// decode decodes data written by encode.
+func (b *BadgerCache) decode(data []byte) (interface{}, error) {
+	if b.codec != nil {
+		return decodeWith(b.codec, data)
+	}
+	return decodeValue(data)
+}
 
-	return nil
+// value reads and decodes the string value stored at key.
+func (b *BadgerCache) value(txn *badger.Txn, key string) (interface{}, *badger.Item, error) {
+	item, err := txn.Get(b.key(key))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil, errNotFound(key)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	switch item.UserMeta() {
+	case badgerMetaString:
+		val, err := item.ValueCopy(nil)
+		return val, item, err
+	case badgerMetaValue:
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, item, err
+		}
+		val, err := b.decode(data)
+		return val, item, err
+	default:
+		return nil, item, errIsHash(key)
+	}
 }
 
-// Decr decreases cached int-type value by given key as a counter.
-func (c *BadgerCache) Decr(key string) error {
+// DelCtx deletes cached value by given key.
+func (b *BadgerCache) DelCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	return b.update(ctx, func(txn *badger.Txn) error {
+		return b.delete(txn, key)
+	})
+}
 
-	return nil
+// IncrCtx increases cached int-type value by given key as a counter.
+func (b *BadgerCache) IncrCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.incrBy(ctx, key, 1)
 }
 
-// IsExist returns true if cached value exists.
-func (c *BadgerCache) Exists(key string) bool {
+// DecrCtx decreases cached int-type value by given key as a counter.
+func (b *BadgerCache) DecrCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.incrBy(ctx, key, -1)
+}
 
-	return false
+// incrBy atomically adds delta, 1 or -1, to the integer stored at key, keeping its expiration.
+func (b *BadgerCache) incrBy(ctx context.Context, key string, delta int64) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	return b.update(ctx, func(txn *badger.Txn) error {
+		val, item, err := b.value(txn, key)
+		if err != nil {
+			return err
+		}
+		if raw, ok := val.([]byte); ok && item.UserMeta() == badgerMetaString {
+			val = string(raw)
+		}
+		if b.codec != nil {
+			val = counterValue(val)
+		}
+		if delta > 0 {
+			val, err = Incr(val)
+		} else {
+			val, err = Decr(val)
+		}
+		if err != nil {
+			return err
+		}
+		data, err := b.encode(val)
+		if err != nil {
+			return err
+		}
+		e := badger.NewEntry(b.key(key), data).WithMeta(badgerMetaValue)
+		e.ExpiresAt = item.ExpiresAt()
+		return txn.SetEntry(e)
+	})
 }
 
-// Flush deletes all cached data.
-func (c *BadgerCache) Flush() error {
+// ExistsCtx returns true if cached value exists.
+func (b *BadgerCache) ExistsCtx(ctx context.Context, key string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err := b.acquire(); err != nil {
+		return false
+	}
+	defer b.release()
+	return b.Handle.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(b.key(key))
+		return err
+	}) == nil
+}
 
-	return nil
+// FlushCtx deletes all cached data.
+func (b *BadgerCache) FlushCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	if b.prefix == "" {
+		return b.Handle.DropAll()
+	}
+	return b.Handle.DropPrefix([]byte(b.prefix))
 }
 
+// StartAndGC opens the database and starts the value log GC routine.
+// AdapterConfig: path=/badger,prefix=cache:,num_memtables=2,value_log_file_size=256,num_compactors=2,
+// compression=snappy,sync_writes=false,gc_interval=5m,gc_discard_ratio=0.9,
+// encryption_key=<base64 AES key>,index_cache_size=100
+// Options left out keep their current value.
 func (b *BadgerCache) StartAndGC(opts Options) (err error) {
 	_ = b.Close()
+	if err = b.configure(opts.AdapterConfig); err != nil {
+		return err
+	}
+	if b.codec, err = codecOf(opts, "binary"); err != nil {
+		return err
+	}
+	if b.fields, err = fieldCipherOf(opts); err != nil {
+		return err
+	}
 	if b.Path == "" {
 		return errors.New("path undefined")
 	}
@@ -125,59 +304,276 @@ func (b *BadgerCache) StartAndGC(opts Options) (err error) {
 		WithNumCompactors(b.NumCompactors).
 		WithCompression(b.Compression).
 		WithSyncWrites(b.SyncWrites)
+	if len(b.EncryptionKey) > 0 {
+		// Badger needs an index cache to keep decrypted block indexes.
+		if b.IndexCacheSize <= 0 {
+			b.IndexCacheSize = 100
+		}
+		opt = opt.WithEncryptionKey(b.EncryptionKey).WithIndexCacheSize(b.IndexCacheSize << 20)
+	}
 
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	if b.Handle, err = badger.Open(opt); err != nil {
 		return err
 	}
-	b.onceGC.Do(func() {
-		go b.autoGC()
-	})
-
-	return nil
-}
-
-func (b *BadgerCache) autoGC() {
+	b.closed.Store(false)
 	td := b.GcInterval.Duration()
 	if td == 0 {
 		b.GcInterval.Number = 5
 		b.GcInterval.Unit = "minute"
 		td = 5 * time.Minute
 	}
-	ticker := time.NewTicker(td)
-	defer ticker.Stop()
-	for range ticker.C {
-	again:
-		if err := b.RunValueLogGC(); err == nil {
-			goto again
+	b.gc = runGC(td, b.collect)
+
+	return nil
+}
+
+// configure applies the AdapterConfig options.
+func (b *BadgerCache) configure(config string) error {
+	cfg, err := ini.Load([]byte(strings.Replace(config, ",", "\n", -1)))
+	if err != nil {
+		return err
+	}
+	for k, v := range cfg.Section("").KeysHash() {
+		switch k {
+		case "path":
+			b.Path = v
+		case "prefix":
+			b.prefix = v
+		case "num_memtables":
+			b.NumMemtables, err = strconv.Atoi(v)
+		case "value_log_file_size":
+			b.ValueLogFileSize, err = strconv.ParseInt(v, 10, 64)
+		case "num_compactors":
+			b.NumCompactors, err = strconv.Atoi(v)
+		case "compression":
+			b.Compression, err = parseBadgerCompression(v)
+		case "sync_writes":
+			b.SyncWrites, err = strconv.ParseBool(v)
+		case "gc_interval":
+			b.GcInterval, err = parseBadgerInterval(v)
+		case "encryption_key":
+			b.EncryptionKey, err = base64.StdEncoding.DecodeString(v)
+			if err == nil && len(b.EncryptionKey) != 16 && len(b.EncryptionKey) != 24 && len(b.EncryptionKey) != 32 {
+				err = errors.New("must be 16, 24 or 32 bytes")
+			}
+		case "index_cache_size":
+			b.IndexCacheSize, err = strconv.ParseInt(v, 10, 64)
+		case "gc_discard_ratio":
+			b.GcDiscardRatio, err = strconv.ParseFloat(v, 64)
+			if err == nil && (b.GcDiscardRatio <= 0 || b.GcDiscardRatio > 1) {
+				err = errors.New("must be in (0, 1]")
+			}
+		default:
+			return fmt.Errorf("cache/badger: unsupported option '%s'", k)
+		}
+		if err != nil {
+			return fmt.Errorf("cache/badger: invalid value '%s' for option '%s': %v", v, k, err)
 		}
 	}
+	return nil
+}
+
+// parseBadgerCompression accepts none, snappy, zstd or their numeric values.
+func parseBadgerCompression(v string) (options.CompressionType, error) {
+	switch strings.ToLower(v) {
+	case "none", "0":
+		return options.None, nil
+	case "snappy", "1":
+		return options.Snappy, nil
+	case "zstd", "2":
+		return options.ZSTD, nil
+	default:
+		return options.None, errors.New("want none, snappy or zstd")
+	}
+}
+
+// parseBadgerInterval accepts a duration like 5m or a number of seconds.
+func parseBadgerInterval(v string) (timex.Duration, error) {
+	if sec, err := strconv.Atoi(v); err == nil {
+		return timex.Duration{Number: sec, Unit: timex.DurationSecond}, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return timex.Duration{}, err
+	}
+	if d < time.Second {
+		return timex.Duration{}, errors.New("must be at least 1s")
+	}
+	return timex.Duration{Number: int(d / time.Second), Unit: timex.DurationSecond}, nil
+}
+
+// collect runs the value log GC until there is nothing left to rewrite.
+func (b *BadgerCache) collect() {
+	for b.RunValueLogGC() == nil {
+	}
 }
 func (b *BadgerCache) RunValueLogGC() error {
-	if err := b.undefined(); err != nil {
+	if err := b.acquire(); err != nil {
 		return err
 	}
+	defer b.release()
 	if b.GcDiscardRatio <= 0 || b.GcDiscardRatio > 1 {
 		b.GcDiscardRatio = 0.9
 	}
 	return b.Handle.RunValueLogGC(b.GcDiscardRatio)
 }
 
-func (b *BadgerCache) undefined() error {
-	if b == nil || b.Handle == nil || b.Handle.IsClosed() {
-		return errors.New("client uninitialized or is closed")
+// acquire read locks the database until release is called, so Close waits
+// for the operations in flight. It returns ErrClosed without locking when the
+// database is closed or was never opened.
+func (b *BadgerCache) acquire() error {
+	if b == nil || b.closed.Load() {
+		return errBadgerClosed
+	}
+	b.lock.RLock()
+	if b.closed.Load() || b.Handle == nil || b.Handle.IsClosed() {
+		b.lock.RUnlock()
+		return errBadgerClosed
 	}
 	return nil
 }
 
+// release unlocks the database locked by acquire.
+func (b *BadgerCache) release() {
+	b.lock.RUnlock()
+}
+
+var errBadgerClosed = fmt.Errorf("%w: badger client uninitialized or closed", ErrClosed)
+
+// badgerExpiresAt returns the unix time at which an entry living for ttl
+// expires, rounded up, 0 if ttl is not positive.
+func badgerExpiresAt(ttl time.Duration) uint64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return uint64(unixExpiry(time.Now(), ttl))
+}
+
+func (b *BadgerCache) key(key string) []byte {
+	return []byte(b.prefix + key)
+}
+
+func (b *BadgerCache) fieldKey(key, field string) []byte {
+	return []byte(b.prefix + key + badgerHashSep + field)
+}
+
+// update runs fn in a read-write transaction, retrying it on conflicts
+// until ctx is done so read-modify-write operations stay atomic.
+func (b *BadgerCache) update(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	for {
+		if err := b.Handle.Update(fn); err != badger.ErrConflict {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// delete removes key together with its hash fields.
+func (b *BadgerCache) delete(txn *badger.Txn, key string) error {
+	item, err := txn.Get(b.key(key))
+	if err == badger.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if item.UserMeta() == badgerMetaHash {
+		fields, err := b.fieldKeys(txn, key)
+		if err != nil {
+			return err
+		}
+		for _, k := range fields {
+			if err = txn.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return txn.Delete(b.key(key))
+}
+
+// fieldKeys returns the storage keys of the fields of hash key.
+func (b *BadgerCache) fieldKeys(txn *badger.Txn, key string) (keys [][]byte, err error) {
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	opt.Prefix = b.fieldKey(key, "")
+	it := txn.NewIterator(opt)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+	return keys, nil
+}
+
+// hash returns the marker item of hash key, or nil if key does not exist.
+func (b *BadgerCache) hash(txn *badger.Txn, key string) (*badger.Item, error) {
+	item, err := txn.Get(b.key(key))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if item.UserMeta() != badgerMetaHash {
+		return nil, errNotHash(key)
+	}
+	return item, nil
+}
+
+// hset merges fields into hash key, creating it if needed.
+func (b *BadgerCache) hset(ctx context.Context, key string, fields map[string]string) error {
+	fields, err := b.fields.sealAll(fields)
+	if err != nil {
+		return err
+	}
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	return b.update(ctx, func(txn *badger.Txn) error {
+		item, err := b.hash(txn, key)
+		if err != nil {
+			return err
+		}
+		var expiresAt uint64
+		if item != nil {
+			expiresAt = item.ExpiresAt()
+		} else {
+			e := badger.NewEntry(b.key(key), nil).WithMeta(badgerMetaHash)
+			if err = txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		for k, v := range fields {
+			e := badger.NewEntry(b.fieldKey(key, k), []byte(v)).WithMeta(badgerMetaHashField)
+			e.ExpiresAt = expiresAt
+			if err = txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 /**
  * @desc: 存入map数据
  * @param {string} key
  * @param {interface{}} data
  * @return {*}
  */
-func (c *BadgerCache) HMSet(key string, data interface{}) error {
-
-	return nil
+func (b *BadgerCache) HMSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if key == "" || data == nil {
+		return errors.New("parameter is empty")
+	}
+	fields, err := hashFields(data)
+	if err != nil {
+		return err
+	}
+	return b.hset(ctx, key, fields)
 }
 
 /**
@@ -186,8 +582,16 @@ func (c *BadgerCache) HMSet(key string, data interface{}) error {
  * @param {interface{}} dst 赋值
  * @return {*}
  */
-func (c *BadgerCache) HMScan(val map[string]string, dst interface{}) (err error) {
-
+func (b *BadgerCache) HMScan(val map[string]string, dst interface{}) (err error) {
+	types := reflect.TypeOf(dst)
+	if !(types.Kind() == reflect.Ptr && types.Elem().Kind() == reflect.Struct) {
+		return errors.New("parsing failed")
+	}
+	types = types.Elem()
+	value := reflect.ValueOf(dst).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		scanValue(val, types.Field(i), value.Field(i))
+	}
 	return nil
 }
 
@@ -197,9 +601,39 @@ func (c *BadgerCache) HMScan(val map[string]string, dst interface{}) (err error)
  * @param field 获取的字段
  * @return {*}
  */
-func (c *BadgerCache) HMGet(key string, fields []string) (res map[string]string, err error) {
-
-	return res, nil
+func (b *BadgerCache) HMGetCtx(ctx context.Context, key string, fields []string) (res map[string]string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := b.acquire(); err != nil {
+		return res, err
+	}
+	defer b.release()
+	res = make(map[string]string)
+	err = b.Handle.View(func(txn *badger.Txn) error {
+		if item, err := b.hash(txn, key); err != nil {
+			return err
+		} else if item == nil {
+			return errNotFound(key)
+		}
+		for _, field := range fields {
+			item, err := txn.Get(b.fieldKey(key, field))
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if res[field], err = b.fields.open(string(val)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return res, err
 }
 
 /**
@@ -208,9 +642,35 @@ func (c *BadgerCache) HMGet(key string, fields []string) (res map[string]string,
  * @param field 获取的字段
  * @return {*}
  */
-func (c *BadgerCache) HGet(key, field string) (data string, err error) {
-
-	return data, nil
+func (b *BadgerCache) HGetCtx(ctx context.Context, key, field string) (data string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := b.acquire(); err != nil {
+		return data, err
+	}
+	defer b.release()
+	err = b.Handle.View(func(txn *badger.Txn) error {
+		marker, err := b.hash(txn, key)
+		if err != nil {
+			return err
+		} else if marker == nil {
+			return errNotFound(key)
+		}
+		item, err := txn.Get(b.fieldKey(key, field))
+		if err == badger.ErrKeyNotFound {
+			return errFieldNotFound(key, field)
+		} else if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		data, err = b.fields.open(string(val))
+		return err
+	})
+	return data, err
 }
 
 /**
@@ -219,9 +679,18 @@ func (c *BadgerCache) HGet(key, field string) (data string, err error) {
  * @param {interface{}} data
  * @return {*}
  */
-func (c *BadgerCache) HSet(key string, data interface{}) error {
-
-	return nil
+func (b *BadgerCache) HSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if data == nil || reflect.TypeOf(data).Kind() != reflect.Map {
+		return errors.New("data must be map")
+	}
+	fields, err := hashFields(data)
+	if err != nil {
+		return err
+	}
+	return b.hset(ctx, key, fields)
 }
 
 /**
@@ -230,9 +699,32 @@ func (c *BadgerCache) HSet(key string, data interface{}) error {
  * @param {string} field
  * @return {*}
  */
-func (c *BadgerCache) HDel(key, field string) (err error) {
-
-	return err
+func (b *BadgerCache) HDelCtx(ctx context.Context, key, field string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	return b.update(ctx, func(txn *badger.Txn) error {
+		marker, err := b.hash(txn, key)
+		if err != nil || marker == nil {
+			return err
+		}
+		if err = txn.Delete(b.fieldKey(key, field)); err != nil {
+			return err
+		}
+		// Like redis, a hash without fields does not exist anymore.
+		fields, err := b.fieldKeys(txn, key)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return txn.Delete(b.key(key))
+		}
+		return nil
+	})
 }
 
 /**
@@ -240,9 +732,40 @@ func (c *BadgerCache) HDel(key, field string) (err error) {
  * @param 存入hash的key值
  * @return {*}
  */
-func (c *BadgerCache) HGetAll(key string) (data map[string]string, err error) {
-
-	return data, nil
+func (b *BadgerCache) HGetAllCtx(ctx context.Context, key string) (data map[string]string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := b.acquire(); err != nil {
+		return data, err
+	}
+	defer b.release()
+	err = b.Handle.View(func(txn *badger.Txn) error {
+		marker, err := b.hash(txn, key)
+		if err != nil {
+			return err
+		} else if marker == nil {
+			return errNotFound(key)
+		}
+		data = make(map[string]string)
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = b.fieldKey(key, "")
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			field := string(item.Key()[len(opt.Prefix):])
+			if data[field], err = b.fields.open(string(val)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return data, err
 }
 
 /**
@@ -251,9 +774,51 @@ func (c *BadgerCache) HGetAll(key string) (data map[string]string, err error) {
  * @param {time.Duration} expire
  * @return {*}
  */
-func (c *BadgerCache) Expire(key string, expire time.Duration) error {
+func (b *BadgerCache) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	return b.update(ctx, func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(key))
+		if err == badger.ErrKeyNotFound {
+			return errNotFound(key)
+		} else if err != nil {
+			return err
+		}
+		if expire <= 0 {
+			return b.delete(txn, key)
+		}
+		expiresAt := badgerExpiresAt(expire)
 
-	return nil
+		keys := [][]byte{b.key(key)}
+		if item.UserMeta() == badgerMetaHash {
+			fields, err := b.fieldKeys(txn, key)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, fields...)
+		}
+		for _, k := range keys {
+			item, err := txn.Get(k)
+			if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			e := badger.NewEntry(k, val).WithMeta(item.UserMeta())
+			e.ExpiresAt = expiresAt
+			if err = txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 /**
@@ -261,64 +826,274 @@ func (c *BadgerCache) Expire(key string, expire time.Duration) error {
  * @param {*} bucket
  * @return {*}
  */
-func (b *BadgerCache) Clear(key string) (err error) {
-	if err := b.undefined(); err != nil {
+func (b *BadgerCache) ClearCtx(ctx context.Context, bucket string) (err error) {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	return b.Handle.DropPrefix([]byte(b.prefix))
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	if b.prefix+bucket == "" {
+		return b.Handle.DropAll()
+	}
+	return b.Handle.DropPrefix(b.key(bucket))
+}
 
+// SizeCtx returns the estimated number of bytes held by keys under bucket.
+func (b *BadgerCache) SizeCtx(ctx context.Context, bucket string) string {
+	if ctx.Err() != nil {
+		return "0"
+	}
+	var size int64
+	b.iterate(ctx, bucket, true, func(item *badger.Item) {
+		size += item.EstimatedSize()
+	})
+	return fmt.Sprintf("%d", size)
 }
 
-func (c *BadgerCache) Size(bucket string) string {
-	var size uint64
-	filepath.Walk(bucket, func(_ string, info os.FileInfo, err error) error {
-		if info == nil {
-			return nil
+// TTLCtx returns the remaining time to live of key, -1 if it has no expiration
+// and -2 if it does not exist.
+func (b *BadgerCache) TTLCtx(ctx context.Context, key string) (ttl time.Duration) {
+	if ctx.Err() != nil {
+		return -2
+	}
+	ttl = -2
+	if err := b.acquire(); err != nil {
+		return ttl
+	}
+	defer b.release()
+	b.Handle.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(key))
+		if err != nil {
+			return err
 		}
-		if !info.IsDir() {
-			size += uint64(info.Size())
+		if item.ExpiresAt() == 0 {
+			ttl = -1
+		} else {
+			ttl = time.Until(time.Unix(int64(item.ExpiresAt()), 0))
 		}
 		return nil
 	})
-	return fmt.Sprintf("%d", size)
+	return ttl
 }
 
-func (c *BadgerCache) TTL(key string) time.Duration {
-
-	return 1
+// TypeCtx returns the type of value stored at key: "string", "hash" or "none".
+func (b *BadgerCache) TypeCtx(ctx context.Context, key string) (res string) {
+	if ctx.Err() != nil {
+		return "none"
+	}
+	res = "none"
+	if err := b.acquire(); err != nil {
+		return res
+	}
+	defer b.release()
+	b.Handle.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key(key))
+		if err != nil {
+			return err
+		}
+		if item.UserMeta() == badgerMetaHash {
+			res = "hash"
+		} else {
+			res = "string"
+		}
+		return nil
+	})
+	return res
 }
 
-func (c *BadgerCache) Type(key string) string {
-
-	return ""
+// SearchCtx returns the keys starting with bucket, without the configured prefix.
+func (b *BadgerCache) SearchCtx(ctx context.Context, bucket string) []string {
+	if ctx.Err() != nil {
+		return []string{}
+	}
+	keys := []string{}
+	b.iterate(ctx, bucket, false, func(item *badger.Item) {
+		keys = append(keys, string(item.Key()[len(b.prefix):]))
+	})
+	return keys
 }
 
-func (c *BadgerCache) Search(bucket string) []string {
+// ScanCtx lists the keys starting with prefix in lexical order, see Cache.Scan.
+func (b *BadgerCache) ScanCtx(ctx context.Context, prefix, cursor string, limit int) (keys []KeyInfo, next string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if err := b.acquire(); err != nil {
+		return nil, "", err
+	}
+	defer b.release()
+	limit = scanLimit(limit)
+	err = b.Handle.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		opt.Prefix = b.key(prefix)
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		it.Rewind()
+		if len(cursor) > 0 {
+			it.Seek(b.key(cursor))
+		}
+		for ; it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			key := string(item.Key()[len(b.prefix):])
+			if item.UserMeta() == badgerMetaHashField || key == cursor {
+				continue
+			}
+			if len(keys) == limit {
+				next = keys[limit-1].Key
+				return nil
+			}
+			info := KeyInfo{Key: key, TTL: -1, Type: "string"}
+			if item.ExpiresAt() > 0 {
+				info.TTL = time.Until(time.Unix(int64(item.ExpiresAt()), 0))
+			}
+			if item.UserMeta() == badgerMetaHash {
+				info.Type = "hash"
+			}
+			keys = append(keys, info)
+		}
+		return nil
+	})
+	return keys, next, err
+}
 
-	return []string{}
+// iterate calls fn for every key starting with bucket, hash fields included if fields is set.
+func (b *BadgerCache) iterate(ctx context.Context, bucket string, fields bool, fn func(item *badger.Item)) {
+	if err := b.acquire(); err != nil {
+		return
+	}
+	defer b.release()
+	b.Handle.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		opt.Prefix = b.key(bucket)
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid() && ctx.Err() == nil; it.Next() {
+			if fields || it.Item().UserMeta() != badgerMetaHashField {
+				fn(it.Item())
+			}
+		}
+		return nil
+	})
 }
 
+// Close stops the value log GC routine and closes the database.
 func (b *BadgerCache) Close() error {
-	if err := b.undefined(); err != nil {
-		return err
-	}
-	if err := b.Handle.Close(); err != nil {
-		return err
+	b.lock.Lock()
+	gc := b.gc
+	b.gc = nil
+	b.lock.Unlock()
+	// The GC routine may be waiting for the lock, stop it first.
+	gc.stop()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed.Swap(true) || b.Handle == nil {
+		return errBadgerClosed
 	}
+	err := b.Handle.Close()
 	b.Handle = nil
-	return nil
+	return err
+}
+
+// Cache methods wrapping their Ctx variants with a background context.
+
+func (b *BadgerCache) Set(key string, val interface{}, ttl int64) error {
+	return b.SetCtx(context.Background(), key, val, ttl)
+}
+
+func (b *BadgerCache) SetEx(key string, val interface{}, ttl time.Duration) error {
+	return b.SetExCtx(context.Background(), key, val, ttl)
+}
+
+func (b *BadgerCache) Get(key string) (res interface{}, err error) {
+	return b.GetCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Del(key string) error {
+	return b.DelCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Incr(key string) error {
+	return b.IncrCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Decr(key string) error {
+	return b.DecrCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Exists(key string) bool {
+	return b.ExistsCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Flush() error {
+	return b.FlushCtx(context.Background())
+}
+
+func (b *BadgerCache) HMSet(key string, data interface{}) error {
+	return b.HMSetCtx(context.Background(), key, data)
+}
+
+func (b *BadgerCache) HMGet(key string, fields []string) (res map[string]string, err error) {
+	return b.HMGetCtx(context.Background(), key, fields)
+}
+
+func (b *BadgerCache) HGet(key, field string) (data string, err error) {
+	return b.HGetCtx(context.Background(), key, field)
+}
+
+func (b *BadgerCache) HSet(key string, data interface{}) error {
+	return b.HSetCtx(context.Background(), key, data)
+}
+
+func (b *BadgerCache) HDel(key, field string) (err error) {
+	return b.HDelCtx(context.Background(), key, field)
+}
+
+func (b *BadgerCache) HGetAll(key string) (data map[string]string, err error) {
+	return b.HGetAllCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Expire(key string, expire time.Duration) error {
+	return b.ExpireCtx(context.Background(), key, expire)
+}
+
+func (b *BadgerCache) Clear(bucket string) (err error) {
+	return b.ClearCtx(context.Background(), bucket)
+}
+
+func (b *BadgerCache) Size(bucket string) string {
+	return b.SizeCtx(context.Background(), bucket)
+}
+
+func (b *BadgerCache) TTL(key string) (ttl time.Duration) {
+	return b.TTLCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Type(key string) (res string) {
+	return b.TypeCtx(context.Background(), key)
+}
+
+func (b *BadgerCache) Search(bucket string) []string {
+	return b.SearchCtx(context.Background(), bucket)
+}
+
+func (b *BadgerCache) Scan(prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	return b.ScanCtx(context.Background(), prefix, cursor, limit)
 }
 
 func init() {
-	Register("badger", &BadgerCache{
-		Path:             "/badger",
-		NumMemtables:     2,
-		MaxTableSize:     16,
-		ValueLogFileSize: 256, // 设置512在1G内存下会占用过高
-		NumCompactors:    2,
-		Compression:      1,
-		SyncWrites:       false,
-		GcInterval:       timex.Duration{Number: 5, Unit: timex.DurationMinute},
-		GcDiscardRatio:   0.9,
+	RegisterFactory("badger", func(opt Options) (Cache, error) {
+		c := NewBadgerCache()
+		if err := c.StartAndGC(opt); err != nil {
+			return nil, err
+		}
+		return c, nil
 	})
 }
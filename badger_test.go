@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBadgerCloseWhileInUse(t *testing.T) {
+	c := NewBadgerCache()
+	if err := c.StartAndGC(Options{AdapterConfig: "path=" + filepath.Join(t.TempDir(), "badger")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			key := "key" + strconv.Itoa(i)
+			for j := 0; j < 200; j++ {
+				if err := c.Set(key, j, 0); err != nil {
+					if !errors.Is(err, ErrClosed) {
+						t.Errorf("Set = %v, want nil or ErrClosed", err)
+					}
+					return
+				}
+				c.Get(key)
+				c.TTL(key)
+				c.Search("key")
+			}
+		}(i)
+	}
+	close(start)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if err := c.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close = %v, want ErrClosed", err)
+	}
+	if _, err := c.Get("key0"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Get = %v, want ErrClosed", err)
+	}
+}
@@ -1,16 +1,26 @@
 package cache
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"time"
-
-	"gopkg.in/ini.v1"
 )
 
 // Cache is the interface that operates the cache data.
+//
+// Expiration works the same for every adapter: Set takes whole seconds and
+// SetEx a time.Duration, a zero or negative expiration means the value never
+// expires. Expire with a zero or negative duration deletes the key, and TTL
+// returns -1 for a key without expiration and -2 for a missing key.
+// File and badger adapters store expirations with one second granularity
+// and round them up to the next whole second, redis rounds them up to the
+// millisecond, so values never expire early.
 type Cache interface {
-	// Put puts value into cache with key and expire time.
+	// Put puts value into cache with key and expire time in seconds.
 	Set(key string, val interface{}, timeout int64) error
+	// SetEx puts value into cache with key and expire duration.
+	SetEx(key string, val interface{}, expire time.Duration) error
 	// Get gets cached value by given key.
 	Get(key string) (interface{}, error)
 	// Delete deletes cached value by given key.
@@ -25,11 +35,17 @@ type Cache interface {
 	Flush() error
 	// StartAndGC starts GC routine based on config string settings.
 	StartAndGC(opt Options) error
+	// Close stops the GC routine and releases the connections and files held
+	// by the adapter. Later calls return ErrClosed until StartAndGC is called again.
+	Close() error
 	HMSet(key string, data interface{}) error
 	HMScan(val map[string]string, dst interface{}) error
 	HMGet(key string, fields []string) (data map[string]string, err error)
 	HGet(key, field string) (data string, err error)
+	// HSet sets the fields of the map data in the hash at key, keeping its
+	// other fields and its expiration.
 	HSet(key string, data interface{}) error
+	// HDel deletes field from the hash at key, and the key with its last field.
 	HDel(key, field string) error
 	HGetAll(key string) (data map[string]string, err error)
 	Expire(key string, expire time.Duration) error // 设置有效期
@@ -38,8 +54,48 @@ type Cache interface {
 	TTL(key string) time.Duration
 	Type(key string) string
 	Search(bucket string) []string
+	// Scan lists the keys starting with prefix a page of at most limit keys
+	// at a time, 100 if limit is 0 or less. It starts with an empty cursor
+	// and continues with the returned next cursor until it is empty. A page
+	// may hold fewer keys than limit, even none, before the last one.
+	Scan(prefix, cursor string, limit int) (keys []KeyInfo, next string, err error)
+}
+
+// CacheContext is the companion of Cache whose methods take a context, so
+// request deadlines and cancellation propagate into the adapter. Every
+// adapter implements it, their Cache methods use a background context.
+type CacheContext interface {
+	SetCtx(ctx context.Context, key string, val interface{}, timeout int64) error
+	SetExCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error
+	GetCtx(ctx context.Context, key string) (interface{}, error)
+	DelCtx(ctx context.Context, key string) error
+	IncrCtx(ctx context.Context, key string) error
+	DecrCtx(ctx context.Context, key string) error
+	ExistsCtx(ctx context.Context, key string) bool
+	FlushCtx(ctx context.Context) error
+	HMSetCtx(ctx context.Context, key string, data interface{}) error
+	HMGetCtx(ctx context.Context, key string, fields []string) (data map[string]string, err error)
+	HGetCtx(ctx context.Context, key, field string) (data string, err error)
+	HSetCtx(ctx context.Context, key string, data interface{}) error
+	HDelCtx(ctx context.Context, key, field string) error
+	HGetAllCtx(ctx context.Context, key string) (data map[string]string, err error)
+	ExpireCtx(ctx context.Context, key string, expire time.Duration) error
+	ClearCtx(ctx context.Context, bucket string) error
+	SizeCtx(ctx context.Context, bucket string) string
+	TTLCtx(ctx context.Context, key string) time.Duration
+	TypeCtx(ctx context.Context, key string) string
+	SearchCtx(ctx context.Context, bucket string) []string
+	ScanCtx(ctx context.Context, prefix, cursor string, limit int) (keys []KeyInfo, next string, err error)
 }
 
+var (
+	_ CacheContext = (*MemoryCache)(nil)
+	_ CacheContext = (*FileCache)(nil)
+	_ CacheContext = (*RedisCache)(nil)
+	_ CacheContext = (*BadgerCache)(nil)
+	_ CacheContext = (*TieredCache)(nil)
+)
+
 // Options represents a struct for specifying configuration options for the cache middleware.
 type Options struct {
 	// Name of adapter. Default is "memory".
@@ -52,11 +108,22 @@ type Options struct {
 	OccupyMode bool
 	// Configuration section name. Default is "cache".
 	Section string
+	// Name of the codec values are stored with, see RegisterCodec.
+	// Default is empty, which keeps the encoding of the adapter.
+	Codec string
+	// Compression of the encoded values, one of gzip, zstd or snappy.
+	// Default is empty, which stores them uncompressed.
+	Compression string
+	// Size in bytes below which values are stored uncompressed. Default is 1024.
+	CompressMinSize int
+	// AES keys values are encrypted with, see ParseEncryptionKeys.
+	// Default is empty, which stores them unencrypted.
+	EncryptionKeys string
 }
 
-var cfg *ini.File
-
-func prepareOptions(options []Options) Options {
+// prepareOptions fills the options left empty from the configuration section
+// opt.Section, see LoadConfig, and then from the defaults.
+func prepareOptions(options []Options) (Options, error) {
 	var opt Options
 	if len(options) > 0 {
 		opt = options[0]
@@ -64,24 +131,55 @@ func prepareOptions(options []Options) Options {
 	if len(opt.Section) == 0 {
 		opt.Section = "cache"
 	}
-	sec := Config().Section(opt.Section)
 
 	if len(opt.Adapter) == 0 {
-		opt.Adapter = sec.Key("ADAPTER").MustString("memory")
+		opt.Adapter = "memory"
+		if v, ok := setting(opt.Section, "ADAPTER"); ok {
+			opt.Adapter = v
+		}
 	}
 	if opt.Interval == 0 {
-		opt.Interval = sec.Key("INTERVAL").MustInt(60)
+		opt.Interval = 60
+		if v, ok := setting(opt.Section, "INTERVAL"); ok {
+			interval, err := strconv.Atoi(v)
+			if err != nil {
+				return opt, fmt.Errorf("cache: invalid INTERVAL '%s' in section '%s'", v, opt.Section)
+			}
+			opt.Interval = interval
+		}
 	}
 	if len(opt.AdapterConfig) == 0 {
-		opt.AdapterConfig = sec.Key("ADAPTER_CONFIG").MustString("cache")
+		opt.AdapterConfig, _ = setting(opt.Section, "ADAPTER_CONFIG")
+	}
+	if len(opt.Codec) == 0 {
+		opt.Codec, _ = setting(opt.Section, "CODEC")
+	}
+	if len(opt.Compression) == 0 {
+		opt.Compression, _ = setting(opt.Section, "COMPRESSION")
+	}
+	if opt.CompressMinSize == 0 {
+		if v, ok := setting(opt.Section, "COMPRESS_MIN_SIZE"); ok {
+			size, err := ParseBytes(v)
+			if err != nil {
+				return opt, fmt.Errorf("cache: invalid COMPRESS_MIN_SIZE '%s' in section '%s'", v, opt.Section)
+			}
+			opt.CompressMinSize = int(size)
+		}
+	}
+	if len(opt.EncryptionKeys) == 0 {
+		opt.EncryptionKeys, _ = setting(opt.Section, "ENCRYPTION_KEYS")
 	}
 
-	return opt
+	return opt, nil
 }
 
 // NewCacher creates and returns a new cacher by given adapter name and configuration.
-// It panics when given adapter isn't registered and starts GC automatically.
+// Adapters registered with RegisterFactory return a new instance on every call,
+// adapters registered with Register share one instance which is restarted with opt.
 func NewCacher(name string, opt Options) (Cache, error) {
+	if factory, ok := factories[name]; ok {
+		return factory(opt)
+	}
 	adapter, ok := adapters[name]
 	if !ok {
 		return nil, fmt.Errorf("cache: unknown adapter '%s'(forgot to import?)", name)
@@ -92,26 +190,47 @@ func NewCacher(name string, opt Options) (Cache, error) {
 // Cacher is a middleware that maps a cache.Cache service into the Macaron handler chain.
 // An single variadic cache.Options struct can be optionally provided to configure.
 func New(options ...Options) (Cache, error) {
-	opt := prepareOptions(options)
+	opt, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
 	return NewCacher(opt.Adapter, opt)
 }
 
-var adapters = make(map[string]Cache)
+// Factory creates and starts a new, independent adapter instance.
+type Factory func(opt Options) (Cache, error)
+
+var (
+	adapters  = make(map[string]Cache)
+	factories = make(map[string]Factory)
+)
 
-// Register registers a adapter.
+// Register registers a adapter instance shared by every New call.
+// It is kept for compatibility, prefer RegisterFactory.
 func Register(name string, adapter Cache) {
 	if adapter == nil {
 		panic("cache: cannot register adapter with nil value")
 	}
-	if _, dup := adapters[name]; dup {
-		panic(fmt.Errorf("cache: cannot register adapter '%s' twice", name))
-	}
+	checkRegistered(name)
 	adapters[name] = adapter
 }
 
-func Config() *ini.File {
-	if cfg == nil {
-		return ini.Empty()
+// RegisterFactory registers a factory that creates a new adapter instance
+// for every New call.
+func RegisterFactory(name string, factory Factory) {
+	if factory == nil {
+		panic("cache: cannot register adapter factory with nil value")
+	}
+	checkRegistered(name)
+	factories[name] = factory
+}
+
+func checkRegistered(name string) {
+	_, dup := adapters[name]
+	if _, ok := factories[name]; ok {
+		dup = true
+	}
+	if dup {
+		panic(fmt.Errorf("cache: cannot register adapter '%s' twice", name))
 	}
-	return cfg
 }
@@ -0,0 +1,376 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testAdapter builds a started cache for the conformance tests.
+type testAdapter struct {
+	name string
+	// wholeSeconds is set for adapters rounding expirations up to a second.
+	wholeSeconds bool
+	new          func(t *testing.T, opt Options) Cache
+}
+
+// testAdapters returns the adapters every conformance test runs against.
+// Redis runs against CACHE_TEST_REDIS_ADDR when set, otherwise against the
+// in-process stand-in.
+func testAdapters() []testAdapter {
+	return []testAdapter{
+		{name: "memory", new: func(t *testing.T, opt Options) Cache {
+			return startTestCache(t, NewMemoryCache(), opt)
+		}},
+		{name: "file", wholeSeconds: true, new: func(t *testing.T, opt Options) Cache {
+			opt.AdapterConfig = filepath.Join(t.TempDir(), "cache")
+			return startTestCache(t, NewFileCache(), opt)
+		}},
+		{name: "badger", wholeSeconds: true, new: func(t *testing.T, opt Options) Cache {
+			opt.AdapterConfig = "path=" + filepath.Join(t.TempDir(), "badger")
+			return startTestCache(t, NewBadgerCache(), opt)
+		}},
+		{name: "redis", new: func(t *testing.T, opt Options) Cache {
+			addr := os.Getenv("CACHE_TEST_REDIS_ADDR")
+			if len(addr) == 0 {
+				addr = newFakeRedis(t).Addr()
+			}
+			opt.AdapterConfig = "addr=" + addr + ",prefix=test:" + t.Name() + ":"
+			c := startTestCache(t, NewRedisCache(), opt)
+			t.Cleanup(func() { c.Clear("") })
+			return c
+		}},
+	}
+}
+
+func startTestCache(t *testing.T, c Cache, opt Options) Cache {
+	t.Helper()
+	if err := c.StartAndGC(opt); err != nil {
+		t.Fatalf("StartAndGC: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// between fails unless min < d <= max.
+func between(t *testing.T, what string, d, min, max time.Duration) {
+	t.Helper()
+	if d <= min || d > max {
+		t.Errorf("%s = %v, want in (%v, %v]", what, d, min, max)
+	}
+}
+
+func TestTTLConformance(t *testing.T) {
+	for _, a := range testAdapters() {
+		a := a
+		t.Run(a.name, func(t *testing.T) {
+			t.Parallel()
+			c := a.new(t, Options{})
+			// Adapters rounding up may keep a value up to a second longer.
+			slack := time.Duration(0)
+			if a.wholeSeconds {
+				slack = time.Second
+			}
+
+			t.Run("missing", func(t *testing.T) {
+				if ttl := c.TTL("missing"); ttl != -2 {
+					t.Errorf("TTL = %v, want -2", ttl)
+				}
+				if err := c.Expire("missing", time.Minute); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Expire = %v, want ErrNotFound", err)
+				}
+			})
+
+			t.Run("no expiration", func(t *testing.T) {
+				for _, expire := range []int64{0, -1} {
+					if err := c.Set("forever", "v", expire); err != nil {
+						t.Fatal(err)
+					}
+					if ttl := c.TTL("forever"); ttl != -1 {
+						t.Errorf("Set(%d): TTL = %v, want -1", expire, ttl)
+					}
+				}
+				if err := c.SetEx("forever", "v", 0); err != nil {
+					t.Fatal(err)
+				}
+				if ttl := c.TTL("forever"); ttl != -1 {
+					t.Errorf("SetEx(0): TTL = %v, want -1", ttl)
+				}
+			})
+
+			t.Run("seconds", func(t *testing.T) {
+				if err := c.Set("seconds", "v", 10); err != nil {
+					t.Fatal(err)
+				}
+				between(t, "TTL", c.TTL("seconds"), 9*time.Second, 10*time.Second+slack)
+			})
+
+			t.Run("duration", func(t *testing.T) {
+				if err := c.SetEx("duration", "v", 1500*time.Millisecond); err != nil {
+					t.Fatal(err)
+				}
+				between(t, "TTL", c.TTL("duration"), time.Second, 1500*time.Millisecond+slack)
+			})
+
+			t.Run("rounding", func(t *testing.T) {
+				if err := c.SetEx("short", "v", 100*time.Millisecond); err != nil {
+					t.Fatal(err)
+				}
+				// Sub-second durations never turn into "no expiration".
+				between(t, "TTL", c.TTL("short"), 0, 100*time.Millisecond+slack)
+			})
+
+			t.Run("expire", func(t *testing.T) {
+				if err := c.Set("expire", "v", 0); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Expire("expire", 20*time.Second); err != nil {
+					t.Fatal(err)
+				}
+				between(t, "TTL", c.TTL("expire"), 19*time.Second, 20*time.Second+slack)
+				if err := c.Expire("expire", 1500*time.Millisecond); err != nil {
+					t.Fatal(err)
+				}
+				between(t, "TTL", c.TTL("expire"), time.Second, 1500*time.Millisecond+slack)
+
+				if err := c.Expire("expire", 0); err != nil {
+					t.Fatal(err)
+				}
+				if c.Exists("expire") || c.TTL("expire") != -2 {
+					t.Error("Expire(0) kept the key")
+				}
+			})
+
+			t.Run("expiry", func(t *testing.T) {
+				if err := c.SetEx("expiry", "v", time.Second); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(900 * time.Millisecond)
+				if !c.Exists("expiry") {
+					t.Fatal("expired early")
+				}
+				time.Sleep(1200*time.Millisecond + slack)
+				if c.Exists("expiry") {
+					t.Fatal("still there after its expiration")
+				}
+				if ttl := c.TTL("expiry"); ttl != -2 {
+					t.Errorf("TTL = %v, want -2", ttl)
+				}
+			})
+		})
+	}
+}
+
+func TestTypeConformance(t *testing.T) {
+	for _, a := range testAdapters() {
+		a := a
+		t.Run(a.name, func(t *testing.T) {
+			t.Parallel()
+			c := a.new(t, Options{})
+			if err := c.Set("user_name", "ann", 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.HSet("user_profile", map[string]string{"age": "30"}); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"user_name": "string", "user_profile": "hash", "user_none": "none"}
+			for key, typ := range want {
+				if got := c.Type(key); got != typ {
+					t.Errorf("Type(%s) = %q, want %q", key, got, typ)
+				}
+			}
+
+			keys, _, err := c.Scan("user_", "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 2 {
+				t.Fatalf("Scan = %+v, want 2 keys", keys)
+			}
+			for _, info := range keys {
+				if info.Type != want[info.Key] {
+					t.Errorf("Scan: %s has type %q, want %q", info.Key, info.Type, want[info.Key])
+				}
+			}
+		})
+	}
+}
+
+func TestTypeMismatchConformance(t *testing.T) {
+	for _, a := range testAdapters() {
+		for _, codec := range []string{"", "gob"} {
+			a, codec := a, codec
+			t.Run(a.name+"/"+codec, func(t *testing.T) {
+				t.Parallel()
+				c := a.new(t, Options{Codec: codec})
+				if err := c.Set("name", "ann", 0); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Set("count", 1, 0); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Set("object", map[string]string{"age": "30"}, 0); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.HSet("profile", map[string]string{"age": "30"}); err != nil {
+					t.Fatal(err)
+				}
+
+				mismatch := func(op string, err error) {
+					t.Helper()
+					if !errors.Is(err, ErrTypeMismatch) {
+						t.Errorf("%s = %v, want ErrTypeMismatch", op, err)
+					}
+				}
+				_, err := c.Get("profile")
+				mismatch("Get(hash)", err)
+				mismatch("Incr(hash)", c.Incr("profile"))
+				mismatch("Decr(hash)", c.Decr("profile"))
+				_, err = c.HGetAll("name")
+				mismatch("HGetAll(string)", err)
+				_, err = c.HGetAll("object")
+				mismatch("HGetAll(object)", err)
+				_, err = c.HGet("count", "age")
+				mismatch("HGet(string)", err)
+				_, err = c.HMGet("name", []string{"age"})
+				mismatch("HMGet(string)", err)
+
+				if err := c.Incr("count"); err != nil {
+					t.Errorf("Incr(string) = %v", err)
+				}
+				if err := c.Incr("name"); !errors.Is(err, ErrNotInteger) {
+					t.Errorf("Incr(text) = %v, want ErrNotInteger", err)
+				}
+			})
+		}
+	}
+}
+
+func TestHashConformance(t *testing.T) {
+	for _, a := range testAdapters() {
+		a := a
+		t.Run(a.name, func(t *testing.T) {
+			t.Parallel()
+			c := a.new(t, Options{})
+			if err := c.HSet("user_profile", map[string]string{"name": "ann", "age": "30"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Expire("user_profile", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			// Setting fields merges them with the others and keeps the TTL.
+			if err := c.HSet("user_profile", map[string]string{"age": "31", "city": "Oslo"}); err != nil {
+				t.Fatal(err)
+			}
+			type profile struct {
+				Lang string `cache:"lang"`
+			}
+			if err := c.HMSet("user_profile", profile{Lang: "nb"}); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"name": "ann", "age": "31", "city": "Oslo", "lang": "nb"}
+			if got, err := c.HGetAll("user_profile"); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("HGetAll = %v, %v, want %v", got, err, want)
+			}
+			between(t, "TTL after HSet", c.TTL("user_profile"), 50*time.Second, time.Minute+time.Second)
+
+			if err := c.HDel("user_profile", "name"); err != nil {
+				t.Fatal(err)
+			}
+			delete(want, "name")
+			if got, err := c.HGetAll("user_profile"); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("HGetAll after HDel = %v, %v, want %v", got, err, want)
+			}
+			between(t, "TTL after HDel", c.TTL("user_profile"), 50*time.Second, time.Minute+time.Second)
+
+			// Deleting the last field removes the key.
+			for field := range want {
+				if err := c.HDel("user_profile", field); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.Exists("user_profile") {
+				t.Error("key with no fields left still exists")
+			}
+			if typ := c.Type("user_profile"); typ != "none" {
+				t.Errorf("Type = %q, want none", typ)
+			}
+			if _, err := c.HGetAll("user_profile"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("HGetAll = %v, want ErrNotFound", err)
+			}
+			if err := c.HDel("user_profile", "age"); err != nil {
+				t.Errorf("HDel of a missing key = %v", err)
+			}
+
+			// Fields are not set over a string.
+			if err := c.Set("user_name", "ann", 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.HSet("user_name", map[string]string{"age": "30"}); !errors.Is(err, ErrTypeMismatch) {
+				t.Errorf("HSet(string) = %v, want ErrTypeMismatch", err)
+			}
+			if err := c.HDel("user_name", "age"); !errors.Is(err, ErrTypeMismatch) {
+				t.Errorf("HDel(string) = %v, want ErrTypeMismatch", err)
+			}
+			if val, err := c.Get("user_name"); err != nil || val != "ann" {
+				t.Errorf("Get = %v, %v, want ann", val, err)
+			}
+		})
+	}
+}
+
+func TestScanConformance(t *testing.T) {
+	for _, a := range testAdapters() {
+		a := a
+		t.Run(a.name, func(t *testing.T) {
+			t.Parallel()
+			c := a.new(t, Options{})
+			var want []string
+			for i := 0; i < 60; i++ {
+				key := fmt.Sprintf("user_%02d", i)
+				want = append(want, key)
+				if err := c.Set(key, i, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.Set("post_1", 1, 0)
+
+			seen := make(map[string]bool)
+			var got []string
+			for cursor, pages := "", 0; pages == 0 || len(cursor) > 0; pages++ {
+				keys, next, err := c.Scan("user_", cursor, 7)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(keys) > 7 || pages > 60 {
+					t.Fatalf("page %d: %d keys", pages, len(keys))
+				}
+				for _, info := range keys {
+					got = append(got, info.Key)
+					seen[info.Key] = true
+				}
+				if pages == 0 {
+					// Keys removed during the walk are not listed.
+					for i := len(want) - 1; ; i-- {
+						if !seen[want[i]] {
+							c.Del(want[i])
+							want = append(want[:i], want[i+1:]...)
+							break
+						}
+					}
+				}
+				cursor = next
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("listed %q, want %q", got, want)
+			}
+		})
+	}
+}
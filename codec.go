@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+// Codec encodes cached values into bytes and back. Adapters store values
+// through the codec named by Options.Codec, hash fields are stored as strings
+// whatever the codec.
+type Codec interface {
+	// Name is the content tag the codec is registered and selected by.
+	Name() string
+	// Marshal encodes v.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v, which is a non-nil pointer. Adapters
+	// decode into an *interface{}.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	codecLock sync.RWMutex
+	codecs    = make(map[string]Codec)
+)
+
+// RegisterCodec registers a codec under its name.
+func RegisterCodec(codec Codec) {
+	if codec == nil {
+		panic("cache: cannot register codec with nil value")
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	if _, dup := codecs[codec.Name()]; dup {
+		panic(fmt.Errorf("cache: cannot register codec '%s' twice", codec.Name()))
+	}
+	codecs[codec.Name()] = codec
+}
+
+// GetCodec returns the codec registered under name.
+func GetCodec(name string) (Codec, error) {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown codec '%s'", name)
+	}
+	return codec, nil
+}
+
+// codecOf returns the codec named by Options.Codec wrapped by the configured
+// compression and then encryption. Values to compress or encrypt are encoded
+// by the fallback codec when Options.Codec is empty, otherwise an empty
+// Options.Codec returns nil.
+func codecOf(opt Options, fallback string) (Codec, error) {
+	name := opt.Codec
+	if len(name) == 0 {
+		if len(opt.Compression) == 0 && len(opt.EncryptionKeys) == 0 {
+			return nil, nil
+		}
+		name = fallback
+	}
+	codec, err := GetCodec(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(opt.Compression) > 0 {
+		if codec, err = NewCompressCodec(codec, opt.Compression, opt.CompressMinSize); err != nil {
+			return nil, err
+		}
+	}
+	if len(opt.EncryptionKeys) > 0 {
+		keys, err := ParseEncryptionKeys(opt.EncryptionKeys)
+		if err != nil {
+			return nil, err
+		}
+		return NewEncryptCodec(codec, keys...)
+	}
+	return codec, nil
+}
+
+// decodeWith decodes data with codec into a value of its own choosing.
+func decodeWith(codec Codec, data []byte) (interface{}, error) {
+	var val interface{}
+	err := codec.Unmarshal(data, &val)
+	return val, err
+}
+
+// decodeCounter decodes data for Incr and Decr.
+func decodeCounter(codec Codec, data []byte) (interface{}, error) {
+	val, err := decodeWith(codec, data)
+	return counterValue(val), err
+}
+
+// counterValue turns whole float64 numbers back into integers, codecs like
+// JSON decode every number as float64.
+func counterValue(val interface{}) interface{} {
+	if f, ok := val.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+		return int64(f)
+	}
+	return val
+}
+
+// encodeCounter applies fn, Incr or Decr, to the value encoded in data.
+// On error data is returned unchanged.
+func encodeCounter(codec Codec, data []byte, fn func(interface{}) (interface{}, error)) ([]byte, error) {
+	val, err := decodeCounter(codec, data)
+	if err != nil {
+		return data, err
+	}
+	if val, err = fn(val); err != nil {
+		return data, err
+	}
+	return codec.Marshal(val)
+}
+
+// assign stores val into the value dst points to.
+func assign(dst, val interface{}) error {
+	if p, ok := dst.(*interface{}); ok {
+		*p = val
+		return nil
+	}
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("cache: decode target must be a non-nil pointer")
+	}
+	elem := rv.Elem()
+	v := reflect.ValueOf(val)
+	switch {
+	case !v.IsValid():
+		elem.Set(reflect.Zero(elem.Type()))
+	case v.Type().AssignableTo(elem.Type()):
+		elem.Set(v)
+	case isNumberKind(v.Kind()) && isNumberKind(elem.Kind()):
+		elem.Set(v.Convert(elem.Type()))
+	default:
+		return fmt.Errorf("%w: cannot decode %T into %s", ErrTypeMismatch, val, elem.Type())
+	}
+	return nil
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+// jsonCodec encodes values as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec encodes values with encoding/gob. Like every value gob sends
+// as an interface, custom types must be registered with gob.Register.
+type gobCodec struct{}
+
+// gobValue wraps values so gob records their concrete type.
+type gobValue struct {
+	V interface{}
+}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobValue{v}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	var val gobValue
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&val); err != nil {
+		return err
+	}
+	return assign(v, val.V)
+}
+
+// binaryCodec is the compact type-preserving encoding of encodeValue:
+// scalars come back with their exact kind, other values go through JSON.
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string { return "binary" }
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	return encodeValue(v)
+}
+
+func (binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	if _, ok := v.(*interface{}); !ok && len(data) > 0 && data[0] == valueJSON {
+		return json.Unmarshal(data[1:], v)
+	}
+	val, err := decodeValue(data)
+	if err != nil {
+		return err
+	}
+	return assign(v, val)
+}
+
+// rawCodec stores byte slices and strings as they are.
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "raw" }
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("%w: raw codec cannot encode %T", ErrTypeMismatch, v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *string:
+		*v = string(data)
+		return nil
+	case *[]byte:
+		*v = append([]byte{}, data...)
+		return nil
+	}
+	return assign(v, append([]byte{}, data...))
+}
+
+func init() {
+	// Types the other codecs decode into, so gob round-trips them as well.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(map[string]string{})
+
+	RegisterCodec(jsonCodec{})
+	RegisterCodec(gobCodec{})
+	RegisterCodec(binaryCodec{})
+	RegisterCodec(rawCodec{})
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Header bytes of values written by a compression codec, telling how the
+// rest of the value is compressed.
+const (
+	compressNone byte = iota
+	compressGzip
+	compressZstd
+	compressSnappy
+)
+
+// defaultCompressMinSize is the size below which values are not compressed.
+const defaultCompressMinSize = 1024
+
+var compressions = map[string]byte{
+	"gzip":   compressGzip,
+	"zstd":   compressZstd,
+	"snappy": compressSnappy,
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// compressCodec compresses the values encoded by another codec.
+type compressCodec struct {
+	codec     Codec
+	algorithm string
+	header    byte
+	minSize   int
+}
+
+// NewCompressCodec returns a codec compressing the values encoded by codec
+// with algorithm, one of gzip, zstd or snappy. Values shorter than minSize
+// bytes, 1024 if it is 0, and values that do not shrink are kept as they are.
+// Every value starts with a header byte, so Unmarshal decompresses whatever
+// algorithm wrote it.
+func NewCompressCodec(codec Codec, algorithm string, minSize int) (Codec, error) {
+	header, ok := compressions[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown compression '%s'", algorithm)
+	}
+	if minSize == 0 {
+		minSize = defaultCompressMinSize
+	}
+	return &compressCodec{codec: codec, algorithm: algorithm, header: header, minSize: minSize}, nil
+}
+
+func (c *compressCodec) Name() string {
+	return c.codec.Name() + "+" + c.algorithm
+}
+
+func (c *compressCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) >= c.minSize {
+		packed, err := compress(c.header, data)
+		if err != nil {
+			return nil, err
+		}
+		if len(packed) < len(data) {
+			return append([]byte{c.header}, packed...), nil
+		}
+	}
+	return append([]byte{compressNone}, data...), nil
+}
+
+func (c *compressCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return errors.New("cache: missing compression header")
+	}
+	data, err := decompress(data[0], data[1:])
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, v)
+}
+
+func compress(header byte, data []byte) ([]byte, error) {
+	switch header {
+	case compressGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case compressZstd:
+		if err := loadZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case compressSnappy:
+		return snappy.Encode(nil, data), nil
+	}
+	return data, nil
+}
+
+func decompress(header byte, data []byte) ([]byte, error) {
+	switch header {
+	case compressNone:
+		return data, nil
+	case compressGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case compressZstd:
+		if err := loadZstd(); err != nil {
+			return nil, err
+		}
+		return zstdDecoder.DecodeAll(data, nil)
+	case compressSnappy:
+		return snappy.Decode(nil, data)
+	}
+	return nil, fmt.Errorf("cache: unknown compression header %d", header)
+}
+
+// loadZstd creates the zstd encoder and decoder shared by every codec,
+// both are safe for concurrent use through EncodeAll and DecodeAll.
+func loadZstd() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
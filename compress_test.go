@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func newTestCompressCodec(t *testing.T, algorithm string) Codec {
+	t.Helper()
+	// The raw codec stores values as they are, so tests see what is compressed.
+	codec, err := NewCompressCodec(rawCodec{}, algorithm, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	random := make([]byte, 512)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	for algorithm, header := range compressions {
+		codec := newTestCompressCodec(t, algorithm)
+		for _, tc := range []struct {
+			name   string
+			val    []byte
+			header byte
+		}{
+			{"compressible", bytes.Repeat([]byte("compressible text "), 100), header},
+			// Values below minSize and values not shrinking are kept as is.
+			{"short", []byte("short"), compressNone},
+			{"incompressible", random, compressNone},
+		} {
+			data, err := codec.Marshal(tc.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data[0] != tc.header {
+				t.Errorf("%s %s: header = %d, want %d", algorithm, tc.name, data[0], tc.header)
+			}
+			if tc.header == compressNone && !bytes.Equal(data[1:], tc.val) {
+				t.Errorf("%s %s: stored %.20q, want the value as is", algorithm, tc.name, data[1:])
+			}
+			if tc.header != compressNone && len(data) >= len(tc.val) {
+				t.Errorf("%s %s: %d bytes compressed to %d", algorithm, tc.name, len(tc.val), len(data))
+			}
+
+			// The header tells how a value is compressed, whatever the
+			// algorithm of the codec reading it.
+			for other := range compressions {
+				var got []byte
+				if err := newTestCompressCodec(t, other).Unmarshal(data, &got); err != nil || !bytes.Equal(got, tc.val) {
+					t.Errorf("%s %s: Unmarshal with %s = %.20q, %v", algorithm, tc.name, other, got, err)
+				}
+			}
+		}
+	}
+}
+
+func TestCompressInvalid(t *testing.T) {
+	codec := newTestCompressCodec(t, "gzip")
+	var v []byte
+	if err := codec.Unmarshal([]byte{0x7f, 'a'}, &v); err == nil {
+		t.Error("Unmarshal accepted an unknown header")
+	}
+	if err := codec.Unmarshal(nil, &v); err == nil {
+		t.Error("Unmarshal accepted a value without header")
+	}
+	if err := codec.Unmarshal([]byte{compressGzip, 1, 2, 3}, &v); err == nil {
+		t.Error("Unmarshal accepted corrupt gzip data")
+	}
+	if _, err := NewCompressCodec(rawCodec{}, "lz4", 0); err == nil {
+		t.Error("NewCompressCodec accepted an unknown algorithm")
+	}
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+var (
+	cfgLock sync.RWMutex
+	cfg     *ini.File
+)
+
+// Config returns the configuration New reads its defaults from,
+// an empty file unless one was loaded.
+func Config() *ini.File {
+	cfgLock.RLock()
+	defer cfgLock.RUnlock()
+	if cfg == nil {
+		return ini.Empty()
+	}
+	return cfg
+}
+
+// SetConfig sets the configuration New reads its defaults from.
+func SetConfig(file *ini.File) {
+	cfgLock.Lock()
+	cfg = file
+	cfgLock.Unlock()
+}
+
+// LoadConfig loads the ini file at path as configuration. Each section
+// configures one cache, named by Options.Section:
+//
+//	[cache]
+//	ADAPTER = redis
+//	ADAPTER_CONFIG = addr=:6379,db=0
+//	INTERVAL = 60
+//
+//	[cache.session]
+//	ADAPTER = file
+//	ADAPTER_CONFIG = runtime/session
+//
+// A child section like [cache.session] reads the keys it does not set from
+// its parent [cache]. Environment variables named after the section and the
+// key, like CACHE_ADAPTER or CACHE_SESSION_ADAPTER_CONFIG, override the file.
+func LoadConfig(path string) error {
+	file, err := ini.Load(path)
+	if err != nil {
+		return err
+	}
+	SetConfig(file)
+	return nil
+}
+
+// LoadConfigBytes is LoadConfig for ini data held in memory.
+func LoadConfigBytes(data []byte) error {
+	file, err := ini.Load(data)
+	if err != nil {
+		return err
+	}
+	SetConfig(file)
+	return nil
+}
+
+// setting looks key up in the environment and then in the given section.
+func setting(section, key string) (string, bool) {
+	if v, ok := os.LookupEnv(envName(section, key)); ok {
+		return v, true
+	}
+	sec, err := Config().GetSection(section)
+	if err != nil || !sec.HasKey(key) {
+		return "", false
+	}
+	return sec.Key(key).String(), true
+}
+
+// envName returns the environment variable overriding key in section,
+// "cache.session" and "ADAPTER" give CACHE_SESSION_ADAPTER.
+func envName(section, key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, section)
+	return name + "_" + key
+}
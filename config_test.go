@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+[cache]
+ADAPTER = file
+ADAPTER_CONFIG = runtime/cache
+INTERVAL = 30
+
+[cache.session]
+ADAPTER = redis
+ADAPTER_CONFIG = addr=:6379,db=1
+CODEC = msgpack
+COMPRESS_MIN_SIZE = 2KB
+`
+
+// loadTestConfig loads data as configuration until the end of the test.
+func loadTestConfig(t *testing.T, data string) {
+	t.Helper()
+	if err := LoadConfigBytes([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetConfig(nil) })
+}
+
+func TestPrepareOptionsSections(t *testing.T) {
+	loadTestConfig(t, testConfig)
+
+	opt, err := prepareOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Section != "cache" || opt.Adapter != "file" || opt.AdapterConfig != "runtime/cache" || opt.Interval != 30 {
+		t.Errorf("default section: %+v", opt)
+	}
+
+	opt, err = prepareOptions([]Options{{Section: "cache.session"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Adapter != "redis" || opt.AdapterConfig != "addr=:6379,db=1" || opt.Codec != "msgpack" || opt.CompressMinSize != 2048 {
+		t.Errorf("cache.session: %+v", opt)
+	}
+	// Keys missing from a child section are read from its parent.
+	if opt.Interval != 30 {
+		t.Errorf("cache.session: Interval = %d, want 30 from [cache]", opt.Interval)
+	}
+
+	// Options set by the caller win over the configuration.
+	opt, err = prepareOptions([]Options{{Section: "cache.session", Adapter: "memory", Interval: 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Adapter != "memory" || opt.Interval != 5 || opt.AdapterConfig != "addr=:6379,db=1" {
+		t.Errorf("explicit options: %+v", opt)
+	}
+
+	// Sections absent from the file take the defaults.
+	opt, err = prepareOptions([]Options{{Section: "cache.missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Adapter != "memory" || opt.Interval != 60 || len(opt.AdapterConfig) > 0 {
+		t.Errorf("missing section: %+v", opt)
+	}
+}
+
+func TestPrepareOptionsEnv(t *testing.T) {
+	loadTestConfig(t, testConfig)
+	t.Setenv("CACHE_SESSION_ADAPTER_CONFIG", "addr=redis:6379")
+	t.Setenv("CACHE_INTERVAL", "10")
+
+	opt, err := prepareOptions([]Options{{Section: "cache.session"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.AdapterConfig != "addr=redis:6379" || opt.Adapter != "redis" {
+		t.Errorf("cache.session: %+v", opt)
+	}
+	opt, err = prepareOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Interval != 10 || opt.AdapterConfig != "runtime/cache" {
+		t.Errorf("cache: %+v", opt)
+	}
+
+	for _, tc := range []struct{ section, key, want string }{
+		{"cache", "ADAPTER", "CACHE_ADAPTER"},
+		{"cache.session", "ADAPTER_CONFIG", "CACHE_SESSION_ADAPTER_CONFIG"},
+		{"Cache-L2", "INTERVAL", "CACHE_L2_INTERVAL"},
+	} {
+		if got := envName(tc.section, tc.key); got != tc.want {
+			t.Errorf("envName(%q, %q) = %q, want %q", tc.section, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestPrepareOptionsInvalid(t *testing.T) {
+	loadTestConfig(t, "[cache]\nINTERVAL = 1m\n")
+	if _, err := New(); err == nil || !strings.Contains(err.Error(), "INTERVAL '1m'") {
+		t.Errorf("New = %v, want an invalid INTERVAL error", err)
+	}
+
+	t.Setenv("CACHE_SESSION_INTERVAL", "soon")
+	if _, err := prepareOptions([]Options{{Section: "cache.session"}}); err == nil || !strings.Contains(err.Error(), "section 'cache.session'") {
+		t.Errorf("prepareOptions = %v, want an invalid INTERVAL error", err)
+	}
+
+	if err := LoadConfigBytes([]byte("[cache")); err == nil {
+		t.Error("LoadConfigBytes accepted a malformed file")
+	}
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptionKey is an AES key together with the ID stored in the values it
+// encrypts, so the key can be found again once newer keys are in use.
+type EncryptionKey struct {
+	ID  string
+	Key []byte // 16, 24 or 32 bytes for AES-128, AES-192 or AES-256
+}
+
+// ParseEncryptionKeys parses keys written as id:base64key separated by commas,
+// like "2024b:cGFzc3dvcmQ...,2024a:c2VjcmV0...". The first key is the current one.
+func ParseEncryptionKeys(str string) ([]EncryptionKey, error) {
+	var keys []EncryptionKey
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		if !ok || len(id) == 0 {
+			return nil, fmt.Errorf("cache: encryption key '%s' is not id:base64key", part)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("cache: invalid encryption key '%s': %v", id, err)
+		}
+		keys = append(keys, EncryptionKey{ID: id, Key: key})
+	}
+	return keys, nil
+}
+
+// encryptCodec encrypts the values encoded by another codec with AES-GCM.
+// A value is the length of the key ID, the key ID, the nonce and the sealed
+// data, the key ID is authenticated along with the data.
+type encryptCodec struct {
+	codec   Codec
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewEncryptCodec returns a codec encrypting the values encoded by codec with
+// AES-GCM. The first key encrypts, every key decrypts the values stored with
+// its ID, so keys can be rotated by putting the new key first and dropping the
+// old one once its values have expired.
+func NewEncryptCodec(codec Codec, keys ...EncryptionKey) (Codec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("cache: no encryption key")
+	}
+	c := &encryptCodec{codec: codec, current: keys[0].ID, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for _, key := range keys {
+		if len(key.ID) == 0 || len(key.ID) > 255 {
+			return nil, fmt.Errorf("cache: encryption key ID '%s' must be 1 to 255 bytes", key.ID)
+		}
+		if _, dup := c.aeads[key.ID]; dup {
+			return nil, fmt.Errorf("cache: duplicate encryption key ID '%s'", key.ID)
+		}
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cache: encryption key '%s': %v", key.ID, err)
+		}
+		if c.aeads[key.ID], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *encryptCodec) Name() string {
+	return c.codec.Name() + "+aes-gcm"
+}
+
+func (c *encryptCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.seal(data)
+}
+
+func (c *encryptCodec) Unmarshal(data []byte, v interface{}) error {
+	plain, err := c.open(data)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(plain, v)
+}
+
+// seal encrypts data with the current key.
+func (c *encryptCodec) seal(data []byte) ([]byte, error) {
+	aead := c.aeads[c.current]
+	header := append([]byte{byte(len(c.current))}, c.current...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, data, header), nil
+}
+
+// open decrypts data sealed with any of the keys.
+func (c *encryptCodec) open(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data) < 1+int(data[0]) {
+		return nil, errors.New("cache: malformed encrypted value")
+	}
+	header, rest := data[:1+int(data[0])], data[1+int(data[0]):]
+	id := string(header[1:])
+	aead, ok := c.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("cache: unknown encryption key '%s'", id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("cache: malformed encrypted value")
+	}
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return nil, fmt.Errorf("cache: decrypt with key '%s': %v", id, err)
+	}
+	return plain, nil
+}
+
+// fieldCipher encrypts the values of hash fields, which adapters keep as
+// strings rather than encoding them with the codec. Sealed values are base64
+// encoded so they stay valid strings in every store. A nil fieldCipher leaves
+// the values as they are.
+type fieldCipher struct {
+	enc *encryptCodec
+}
+
+// fieldCipherOf returns the cipher for the encryption keys of opt, nil when
+// there are none.
+func fieldCipherOf(opt Options) (*fieldCipher, error) {
+	if len(opt.EncryptionKeys) == 0 {
+		return nil, nil
+	}
+	keys, err := ParseEncryptionKeys(opt.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := NewEncryptCodec(nil, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCipher{enc: codec.(*encryptCodec)}, nil
+}
+
+// seal returns val encrypted.
+func (f *fieldCipher) seal(val string) (string, error) {
+	if f == nil {
+		return val, nil
+	}
+	data, err := f.enc.seal([]byte(val))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// open returns the value sealed in val.
+func (f *fieldCipher) open(val string) (string, error) {
+	if f == nil {
+		return val, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		return "", errors.New("cache: malformed encrypted value")
+	}
+	plain, err := f.enc.open(data)
+	return string(plain), err
+}
+
+// sealAll returns a copy of fields with their values encrypted.
+func (f *fieldCipher) sealAll(fields map[string]string) (map[string]string, error) {
+	return f.apply(fields, f.seal)
+}
+
+// openAll returns a copy of fields with their values decrypted.
+func (f *fieldCipher) openAll(fields map[string]string) (map[string]string, error) {
+	return f.apply(fields, f.open)
+}
+
+func (f *fieldCipher) apply(fields map[string]string, fn func(string) (string, error)) (map[string]string, error) {
+	if f == nil {
+		return fields, nil
+	}
+	out := make(map[string]string, len(fields))
+	for k, v := range fields {
+		var err error
+		if out[k], err = fn(v); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// testEncryptionKeys returns a fresh key for Options.EncryptionKeys.
+func testEncryptionKeys(t *testing.T) string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return "k1:" + base64.StdEncoding.EncodeToString(key)
+}
+
+type secretProfile struct {
+	Pin string `cache:"pin"`
+}
+
+func TestHashFieldsEncrypted(t *testing.T) {
+	const secret = "plaintext-4111"
+	redis := newFakeRedis(t)
+	file := filepath.Join(t.TempDir(), "cache")
+	badgerPath := filepath.Join(t.TempDir(), "badger")
+
+	for _, tc := range []struct {
+		name   string
+		c      Cache
+		config string
+		// stored returns everything the adapter stored, as it stored it.
+		stored func(c Cache) []byte
+	}{
+		{"memory", NewMemoryCache(), "", func(c Cache) []byte {
+			var buf bytes.Buffer
+			for _, s := range c.(*MemoryCache).allShards() {
+				for _, item := range s.items {
+					for k, v := range item.hash {
+						buf.WriteString(k + v)
+					}
+				}
+			}
+			return buf.Bytes()
+		}},
+		{"file", NewFileCache(), file, func(Cache) []byte {
+			var buf bytes.Buffer
+			filepath.Walk(file, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					data, _ := os.ReadFile(path)
+					buf.Write(data)
+				}
+				return nil
+			})
+			return buf.Bytes()
+		}},
+		{"badger", NewBadgerCache(), "path=" + badgerPath + ",compression=none", func(c Cache) []byte {
+			var buf bytes.Buffer
+			c.(*BadgerCache).Handle.View(func(txn *badger.Txn) error {
+				it := txn.NewIterator(badger.DefaultIteratorOptions)
+				defer it.Close()
+				for it.Rewind(); it.Valid(); it.Next() {
+					val, _ := it.Item().ValueCopy(nil)
+					buf.Write(it.Item().Key())
+					buf.Write(val)
+				}
+				return nil
+			})
+			return buf.Bytes()
+		}},
+		{"redis", NewRedisCache(), "addr=" + redis.Addr(), func(Cache) []byte {
+			return []byte(redis.raw("profile") + redis.raw("user"))
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			startTestCache(t, tc.c, Options{AdapterConfig: tc.config, EncryptionKeys: testEncryptionKeys(t)})
+			if err := tc.c.HSet("user", map[string]string{"card": secret}); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.c.HMSet("profile", secretProfile{Pin: secret}); err != nil {
+				t.Fatal(err)
+			}
+
+			stored := tc.stored(tc.c)
+			if len(stored) == 0 {
+				t.Fatal("found nothing stored")
+			}
+			if bytes.Contains(stored, []byte(secret)) {
+				t.Errorf("hash field value stored in plaintext: %q", stored)
+			}
+
+			if v, err := tc.c.HGet("user", "card"); err != nil || v != secret {
+				t.Errorf("HGet = %q, %v", v, err)
+			}
+			if all, err := tc.c.HGetAll("profile"); err != nil || all["pin"] != secret {
+				t.Errorf("HGetAll = %v, %v", all, err)
+			}
+			if got, err := tc.c.HMGet("user", []string{"card", "missing"}); err != nil || len(got) != 1 || got["card"] != secret {
+				t.Errorf("HMGet = %v, %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors shared by every adapter. Adapters wrap them, so check them with
+// errors.Is rather than comparing directly.
+var (
+	// ErrNotFound reports a missing or expired key, or a missing hash field.
+	ErrNotFound = errors.New("cache: not found")
+	// ErrNotInteger reports an Incr or Decr on a value that is not an integer.
+	ErrNotInteger = errors.New("cache: value is not an integer")
+	// ErrClosed reports a call on a cache that is closed or was never started.
+	ErrClosed = errors.New("cache: closed")
+	// ErrTypeMismatch reports a string operation on a hash or the other way round.
+	ErrTypeMismatch = errors.New("cache: type mismatch")
+)
+
+func errNotFound(key string) error {
+	return fmt.Errorf("%w: key '%s'", ErrNotFound, key)
+}
+
+func errFieldNotFound(key, field string) error {
+	return fmt.Errorf("%w: field '%s' of key '%s'", ErrNotFound, field, key)
+}
+
+func errNotHash(key string) error {
+	return fmt.Errorf("%w: key '%s' does not hold a hash", ErrTypeMismatch, key)
+}
+
+func errIsHash(key string) error {
+	return fmt.Errorf("%w: key '%s' holds a hash", ErrTypeMismatch, key)
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"hash/fnv"
+)
+
+// evictionPolicy decides which key a bounded memory cache drops when it is full.
+// Implementations are not safe for concurrent use, the cache serializes calls.
+type evictionPolicy interface {
+	// add tracks a newly inserted key.
+	add(key string)
+	// access records a read or an overwrite of key, tracked or not.
+	access(key string)
+	// remove stops tracking key.
+	remove(key string)
+	// victim returns the key that should be evicted next.
+	victim() (string, bool)
+	// admit reports whether a new key may displace victim.
+	admit(key, victim string) bool
+}
+
+func newEvictionPolicy(name string, capacity int) (evictionPolicy, error) {
+	switch name {
+	case "", "lru":
+		return newLRUPolicy(), nil
+	case "lfu":
+		return newLFUPolicy(), nil
+	case "tinylfu":
+		return newTinyLFUPolicy(capacity), nil
+	default:
+		return nil, fmt.Errorf("cache/memory: unsupported policy '%s'", name)
+	}
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy struct {
+	ll    *list.List
+	elems map[string]*list.Element
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{ll: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) add(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.ll.PushFront(key)
+}
+
+func (p *lruPolicy) access(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) remove(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.ll.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy) victim() (string, bool) {
+	e := p.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+func (p *lruPolicy) admit(key, victim string) bool {
+	return true
+}
+
+// lfuEntry is a key tracked by lfuPolicy.
+type lfuEntry struct {
+	key   string
+	freq  uint64
+	tick  uint64 // last access, breaks ties in favor of recent keys
+	index int
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].freq < h[j].freq
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// lfuPolicy evicts the least frequently used key.
+type lfuPolicy struct {
+	heap    lfuHeap
+	entries map[string]*lfuEntry
+	tick    uint64
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{entries: make(map[string]*lfuEntry)}
+}
+
+func (p *lfuPolicy) add(key string) {
+	if _, ok := p.entries[key]; ok {
+		p.access(key)
+		return
+	}
+	p.tick++
+	e := &lfuEntry{key: key, freq: 1, tick: p.tick}
+	p.entries[key] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *lfuPolicy) access(key string) {
+	if e, ok := p.entries[key]; ok {
+		p.tick++
+		e.freq++
+		e.tick = p.tick
+		heap.Fix(&p.heap, e.index)
+	}
+}
+
+func (p *lfuPolicy) remove(key string) {
+	if e, ok := p.entries[key]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, key)
+	}
+}
+
+func (p *lfuPolicy) victim() (string, bool) {
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	return p.heap[0].key, true
+}
+
+func (p *lfuPolicy) admit(key, victim string) bool {
+	return true
+}
+
+// tinyLFUPolicy evicts in LRU order but only admits a new key when its
+// estimated access frequency beats the one of the victim.
+type tinyLFUPolicy struct {
+	*lruPolicy
+	sketch *countMinSketch
+}
+
+func newTinyLFUPolicy(capacity int) *tinyLFUPolicy {
+	return &tinyLFUPolicy{lruPolicy: newLRUPolicy(), sketch: newCountMinSketch(capacity)}
+}
+
+func (p *tinyLFUPolicy) access(key string) {
+	p.sketch.increment(key)
+	p.lruPolicy.access(key)
+}
+
+func (p *tinyLFUPolicy) admit(key, victim string) bool {
+	return p.sketch.estimate(key) > p.sketch.estimate(victim)
+}
+
+// countMinSketch is a 4-bit count-min sketch that halves its counters
+// periodically so stale popularity fades out.
+type countMinSketch struct {
+	rows    [4][]uint8
+	mask    uint64
+	added   int
+	resetAt int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 1024
+	for width < capacity {
+		width <<= 1
+	}
+	s := &countMinSketch{mask: uint64(width - 1), resetAt: width * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) indexes(key string) [4]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	lo, hi := sum&0xffffffff, sum>>32
+	var idx [4]uint64
+	for i := range idx {
+		idx[i] = (lo + uint64(i)*hi) & s.mask
+	}
+	return idx
+}
+
+func (s *countMinSketch) increment(key string) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < 15 {
+			s.rows[i][j]++
+		}
+	}
+	s.added++
+	if s.added >= s.resetAt {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+		s.added /= 2
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	min := uint8(15)
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < min {
+			min = s.rows[i][j]
+		}
+	}
+	return min
+}
@@ -11,7 +11,7 @@ func main() {
 	// redis
 	// newCache, err := cache.New(cache.Options{
 	// 	Adapter:       "redis",
-	// 	AdapterConfig: "addr=xxxx,password=xxxx,db=db1,prefix=fasthey",
+	// 	AdapterConfig: "addr=xxxx,password=xxxx,db=1,prefix=fasthey",
 	// 	OccupyMode:    true,
 	// })
 	// if err == nil {
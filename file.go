@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 
@@ -10,11 +11,15 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-json"
+	"gopkg.in/ini.v1"
 )
 
 // Item represents a cache item.
@@ -22,6 +27,16 @@ type Item struct {
 	Val     interface{}
 	Created int64
 	Expire  int64
+	Key     string // the key the item is stored at, empty in files written by older versions
+	Hash    bool   // Val holds the fields of a hash, see updateHash
+}
+
+// kind returns the type of the item as reported by Type: "string" or "hash".
+func (item *Item) kind() string {
+	if item.Hash {
+		return "hash"
+	}
+	return "string"
 }
 
 func (item *Item) hasExpired() bool {
@@ -29,13 +44,51 @@ func (item *Item) hasExpired() bool {
 		(time.Now().Unix()-item.Created) >= item.Expire
 }
 
+// setExpire makes the item created now and expiring after expire, at the
+// next whole second so it does not expire early. Not positive durations
+// never expire.
+func (item *Item) setExpire(expire time.Duration) {
+	now := time.Now()
+	item.Created = now.Unix()
+	item.Expire = 0
+	if expire > 0 {
+		item.Expire = unixExpiry(now, expire) - item.Created
+	}
+}
+
+// ttl returns the remaining time to live of the item, -1 without expiration.
+func (item *Item) ttl() time.Duration {
+	if item.Expire == 0 {
+		return -1
+	}
+	return time.Until(time.Unix(item.Created+item.Expire, 0))
+}
+
 // FileCache represents a file cache adapter implementation.
 type FileCache struct {
 	lock     sync.Mutex
+	locks    [fileLockStripes]sync.Mutex
+	idxLock  sync.Mutex // serializes the index appends and compactions, see lockIndex
 	rootPath string
-	interval int // GC interval.
+	gc       *gcLoop
+	closed   atomic.Bool
+	codec    Codec        // encodes the values when set, otherwise they are stored as JSON
+	fields   *fieldCipher // encrypts the hashes when set
+	syncDir  bool         // fsync directories after renaming a file into them
+	keepBad  bool         // move corrupt files to the quarantine directory instead of deleting them
+	scans    scanSnapshots
 }
 
+const (
+	// fileTempPrefix starts the names of files being written.
+	fileTempPrefix = ".tmp-"
+	// fileQuarantineDir is the directory of the root corrupt files are moved to.
+	fileQuarantineDir = ".quarantine"
+	// fileTempMaxAge is the age past which the GC removes temporary files
+	// left behind by a crash.
+	fileTempMaxAge = time.Hour
+)
+
 // NewFileCache creates and returns a new file cacher.
 func NewFileCache() *FileCache {
 	return &FileCache{}
@@ -52,43 +105,152 @@ func (c *FileCache) filepath(key string) string {
 	return filepath.Join(c.rootPath+path, string(hash[0]), string(hash[1]), hash)
 }
 
-// Set puts value into cache with key and expire time.
-// If expired is 0, it will be deleted by next GC operation.
-func (c *FileCache) Set(key string, val interface{}, expire int64) error {
-	filename := c.filepath(key)
-	if isNotNumber(val) {
+// SetCtx puts value into cache with key and expire time in seconds.
+// If expired is 0, it lives forever.
+func (c *FileCache) SetCtx(ctx context.Context, key string, val interface{}, expire int64) error {
+	return c.SetExCtx(ctx, key, val, time.Duration(expire)*time.Second)
+}
+
+// SetExCtx puts value into cache with key and expire duration, the
+// expiration is rounded up to a whole second.
+func (c *FileCache) SetExCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	if c.codec != nil {
+		data, err := c.codec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		val = data
+	} else if isNotNumber(val) {
 		val, _ = json.Marshal(val)
 	}
-	item := &Item{val, time.Now().Unix(), expire}
+	item := &Item{Val: val}
+	item.setExpire(expire)
+	return c.locked(key, func() error {
+		return c.write(key, item)
+	})
+}
+
+func (c *FileCache) write(key string, item *Item) error {
+	filename := c.filepath(key)
+	item.Key = key
 	data, err := EncodeGob(item)
 	if err != nil {
 		return err
 	}
-	os.MkdirAll(filepath.Dir(filename), os.ModePerm)
-	return os.WriteFile(filename, data, os.ModePerm)
+	_, err = os.Stat(filename)
+	added := os.IsNotExist(err)
+	if err = os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	if err = c.writeFile(filename, data); err != nil || !added {
+		return err
+	}
+	return c.index(key, true)
 }
 
+// writeFile replaces filename with data atomically: data is written to a
+// temporary file of the same directory, synced and renamed over filename, so
+// readers and crashes see either the old or the new content.
+func (c *FileCache) writeFile(filename string, data []byte) (err error) {
+	dir := filepath.Dir(filename)
+	f, err := os.CreateTemp(dir, fileTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return err
+	}
+	if c.syncDir && runtime.GOOS != "windows" {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir flushes the entries of a directory, making renames into it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// remove deletes the file of key and records it in the index.
+func (c *FileCache) remove(key string) error {
+	if err := os.Remove(c.filepath(key)); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return c.index(key, false)
+}
+
+// read returns the item of key, or an ErrNotFound error if it is missing or
+// expired. Expired files are left to the GC.
 func (c *FileCache) read(key string) (*Item, error) {
 	filename := c.filepath(key)
 
 	data, err := os.ReadFile(filename)
-	if err != nil {
+	if os.IsNotExist(err) {
+		return nil, errNotFound(key)
+	} else if err != nil {
 		return nil, err
 	}
 
 	item := new(Item)
-	return item, DecodeGob(data, item)
+	if err = DecodeGob(data, item); err != nil {
+		return nil, err
+	}
+	if item.hasExpired() {
+		return nil, errNotFound(key)
+	}
+	return item, nil
 }
 
-// Get gets cached value by given key.
-func (c *FileCache) Get(key string) (interface{}, error) {
+// GetCtx gets cached value by given key.
+func (c *FileCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
 	item, err := c.read(key)
 	if err != nil {
 		return nil, err
 	}
-	if item.hasExpired() {
-		os.Remove(c.filepath(key))
-		return nil, err
+	if item.Hash {
+		return nil, errIsHash(key)
+	}
+	return c.value(item)
+}
+
+// value decodes the value of item.
+func (c *FileCache) value(item *Item) (interface{}, error) {
+	if data, ok := item.Val.([]byte); ok && c.codec != nil {
+		return decodeWith(c.codec, data)
 	}
 	if isNotNumber(item.Val) {
 		val := item.Val.([]byte)
@@ -99,117 +261,295 @@ func (c *FileCache) Get(key string) (interface{}, error) {
 	return item.Val, nil
 }
 
-// Delete deletes cached value by given key.
-func (c *FileCache) Del(key string) error {
-	return os.Remove(c.filepath(key))
-}
-
-// Incr increases cached int-type value by given key as a counter.
-func (c *FileCache) Incr(key string) error {
-	item, err := c.read(key)
-	if err != nil {
+// DelCtx deletes cached value by given key.
+func (c *FileCache) DelCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
 		return err
 	}
+	return c.locked(key, func() error {
+		return c.remove(key)
+	})
+}
 
-	item.Val, err = Incr(item.Val)
-	if err != nil {
+// IncrCtx increases cached int-type value by given key as a counter.
+func (c *FileCache) IncrCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
 		return err
 	}
-
-	return c.Set(key, item.Val, item.Expire)
+	return c.counter(key, Incr)
 }
 
 // Decrease cached int value.
-func (c *FileCache) Decr(key string) error {
-	item, err := c.read(key)
-	if err != nil {
+func (c *FileCache) DecrCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
 		return err
 	}
+	return c.counter(key, Decr)
+}
 
-	item.Val, err = Decr(item.Val)
-	if err != nil {
-		return err
-	}
+// counter applies op to the counter of key while holding its lock.
+func (c *FileCache) counter(key string, op func(interface{}) (interface{}, error)) error {
+	return c.locked(key, func() error {
+		item, err := c.read(key)
+		if err != nil {
+			return err
+		}
+		if item.Hash {
+			return errIsHash(key)
+		}
+		if data, ok := item.Val.([]byte); ok && c.codec != nil {
+			item.Val, err = encodeCounter(c.codec, data, op)
+		} else {
+			item.Val, err = op(item.Val)
+		}
+		if err != nil {
+			return err
+		}
+		return c.write(key, item)
+	})
+}
 
-	return c.Set(key, item.Val, item.Expire)
+// ExistsCtx returns true if cached value exists.
+func (c *FileCache) ExistsCtx(ctx context.Context, key string) bool {
+	if c.check(ctx) != nil {
+		return false
+	}
+	_, err := c.read(key)
+	return err == nil
 }
 
-// Exists returns true if cached value exists.
-func (c *FileCache) Exists(key string) bool {
-	return IsExist(c.filepath(key))
+// FlushCtx deletes all cached data.
+func (c *FileCache) FlushCtx(ctx context.Context) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	return c.removeAll()
 }
 
-// Flush deletes all cached data.
-func (c *FileCache) Flush() error {
-	return os.RemoveAll(c.rootPath)
+// removeAll deletes every entry of the root but the lock files, which other
+// processes may hold.
+func (c *FileCache) removeAll() error {
+	entries, err := os.ReadDir(c.rootPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == fileLockDir {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.rootPath, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *FileCache) startGC() {
+// collect removes the files of expired items and compacts the indexes.
+func (c *FileCache) collect() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	defer c.compactIndexes()
 
-	if c.interval < 1 {
-		return
-	}
-
-	if err := filepath.Walk(c.rootPath, func(path string, fi os.FileInfo, err error) error {
+	// Errors are logged and skipped, so one bad entry does not stop the sweep.
+	filepath.Walk(c.rootPath, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
-			return fmt.Errorf("Walk: %v", err)
+			if !os.IsNotExist(err) {
+				log.Printf("error garbage collecting cache files: walk: %v", err)
+			}
+			return nil
 		}
 
+		name := fi.Name()
 		if fi.IsDir() {
+			if path != c.rootPath && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, fileTempPrefix) {
+			if time.Since(fi.ModTime()) > fileTempMaxAge {
+				os.Remove(path)
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") {
 			return nil
 		}
 
 		data, err := os.ReadFile(path)
-		if err != nil && !os.IsNotExist(err) {
-			fmt.Errorf("readFile: %v", err)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("error garbage collecting cache files: read: %v", err)
+			}
+			return nil
 		}
 
 		item := new(Item)
 		if err = DecodeGob(data, item); err != nil {
-			return err
+			c.discard(path, err)
+			return nil
 		}
-		if item.hasExpired() {
-			if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("remove: %v", err)
-			}
+		if !item.hasExpired() {
+			return nil
+		}
+		if len(item.Key) == 0 {
+			err = os.Remove(path)
+		} else {
+			// The key may have been written again since it was read.
+			err = c.locked(item.Key, func() error {
+				if _, err := c.read(item.Key); !errors.Is(err, ErrNotFound) {
+					return nil
+				}
+				return c.remove(item.Key)
+			})
+		}
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("error garbage collecting cache files: remove: %v", err)
 		}
 		return nil
-	}); err != nil {
-		log.Printf("error garbage collecting cache files: %v", err)
+	})
+}
+
+// discard deletes the corrupt file at path, or moves it to the quarantine
+// directory when the cache keeps corrupt files.
+func (c *FileCache) discard(path string, cause error) {
+	if !c.keepBad {
+		log.Printf("cache/file: deleting corrupt entry %s: %v", path, cause)
+		os.Remove(path)
+		return
+	}
+	dir := filepath.Join(c.rootPath, fileQuarantineDir)
+	dst := filepath.Join(dir, fmt.Sprintf("%s.%d", filepath.Base(path), time.Now().UnixNano()))
+	log.Printf("cache/file: quarantining corrupt entry %s as %s: %v", path, dst, cause)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Printf("cache/file: quarantine: %v", err)
+		return
+	}
+	if err := os.Rename(path, dst); err != nil {
+		log.Printf("cache/file: quarantine: %v", err)
+	}
+}
+
+// check returns the error of ctx, or ErrClosed once the cache is closed.
+func (c *FileCache) check(ctx context.Context) error {
+	if c.closed.Load() {
+		return ErrClosed
 	}
+	return ctx.Err()
+}
 
-	time.AfterFunc(time.Duration(c.interval)*time.Second, func() { c.startGC() })
+// Close stops the GC routine, the cached files are kept.
+func (c *FileCache) Close() error {
+	c.lock.Lock()
+	if c.closed.Load() {
+		c.lock.Unlock()
+		return ErrClosed
+	}
+	c.closed.Store(true)
+	gc := c.gc
+	c.gc = nil
+	c.lock.Unlock()
+
+	gc.stop()
+	return nil
 }
 
 // StartAndGC starts GC routine based on config string settings.
+// AdapterConfig is the cache directory, default is "cache", or options like
+// path=cache,sync_dir=true,corrupt=quarantine. Relative paths start from the
+// working directory.
 func (c *FileCache) StartAndGC(opt Options) error {
-	c.lock.Lock()
-	exePath, _ := os.Getwd()
-	c.rootPath = exePath + "/" + strings.Replace(opt.AdapterConfig, "./", "", 0)
-	c.interval = opt.Interval
-
-	if !filepath.IsAbs(c.rootPath) {
-		c.rootPath = filepath.Join("/", c.rootPath)
+	path, syncDir, keepBad, err := parseFileConfig(opt.AdapterConfig)
+	if err != nil {
+		return err
+	}
+	rootPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
 	}
+	codec, err := codecOf(opt, "json")
+	if err != nil {
+		return err
+	}
+	fields, err := fieldCipherOf(opt)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	gc := c.gc
+	c.gc = nil
 	c.lock.Unlock()
+	gc.stop()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.codec = codec
+	c.fields = fields
+	c.rootPath = rootPath
+	c.syncDir = syncDir
+	c.keepBad = keepBad
 
 	if err := os.MkdirAll(c.rootPath, os.ModePerm); err != nil {
 		return err
 	}
 
-	go c.startGC()
+	c.closed.Store(false)
+	if opt.Interval > 0 {
+		c.gc = runGC(time.Duration(opt.Interval)*time.Second, c.collect)
+	}
 	return nil
 }
 
+// parseFileConfig parses the AdapterConfig of the file adapter, either a
+// directory or key=value options.
+func parseFileConfig(config string) (path string, syncDir, keepBad bool, err error) {
+	path = "cache"
+	if !strings.Contains(config, "=") {
+		if len(config) > 0 {
+			path = config
+		}
+		return path, false, false, nil
+	}
+	cfg, err := ini.Load([]byte(strings.Replace(config, ",", "\n", -1)))
+	if err != nil {
+		return "", false, false, err
+	}
+	for k, v := range cfg.Section("").KeysHash() {
+		switch k {
+		case "path":
+			path = v
+		case "sync_dir":
+			if syncDir, err = strconv.ParseBool(v); err != nil {
+				return "", false, false, fmt.Errorf("cache/file: invalid sync_dir '%s'", v)
+			}
+		case "corrupt":
+			switch v {
+			case "delete":
+				keepBad = false
+			case "quarantine":
+				keepBad = true
+			default:
+				return "", false, false, fmt.Errorf("cache/file: invalid corrupt '%s', want delete or quarantine", v)
+			}
+		default:
+			return "", false, false, fmt.Errorf("cache/file: unsupported option '%s'", k)
+		}
+	}
+	return path, syncDir, keepBad, nil
+}
+
 /**
  * @desc: 存入map数据
  * @param {string} key
  * @param {interface{}} data
  * @return {*}
  */
-func (c *FileCache) HMSet(key string, data interface{}) error {
+func (c *FileCache) HMSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
 	if key == "" || data == nil {
 		return errors.New("parameter is empty")
 	}
@@ -233,11 +573,13 @@ func (c *FileCache) HMSet(key string, data interface{}) error {
 			}
 		}
 	}
-	err := c.Set(key, values, 0)
-	if err != nil {
-		return errors.New("add failed")
-	}
-	return nil
+	return c.locked(key, func() error {
+		return c.updateHash(key, func(fields map[string]interface{}) {
+			for k, v := range values {
+				fields[k] = v
+			}
+		})
+	})
 }
 
 /**
@@ -269,11 +611,11 @@ func (c *FileCache) HMScan(val map[string]string, dst interface{}) (err error) {
  * @param field 获取的字段
  * @return {*}
  */
-func (c *FileCache) HMGet(key string, fields []string) (res map[string]string, err error) {
-	if !c.Exists(key) {
-		return res, errors.New("does not exist")
+func (c *FileCache) HMGetCtx(ctx context.Context, key string, fields []string) (res map[string]string, err error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
 	}
-	data, err := c.HGetAll(key)
+	data, err := c.HGetAllCtx(ctx, key)
 	if err != nil {
 		return res, err
 	}
@@ -294,19 +636,19 @@ func (c *FileCache) HMGet(key string, fields []string) (res map[string]string, e
  * @param field 获取的字段
  * @return {*}
  */
-func (c *FileCache) HGet(key, field string) (res string, err error) {
-	if !c.Exists(key) {
-		return res, errors.New("does not exist")
+func (c *FileCache) HGetCtx(ctx context.Context, key, field string) (res string, err error) {
+	if err := c.check(ctx); err != nil {
+		return "", err
 	}
-	data, err := c.HGetAll(key)
-	if err == nil {
-		for k, v := range data {
-			if k == field {
-				return v, nil
-			}
-		}
+	data, err := c.HGetAllCtx(ctx, key)
+	if err != nil {
+		return res, err
+	}
+	res, ok := data[field]
+	if !ok {
+		return res, errFieldNotFound(key, field)
 	}
-	return res, errors.New("does not exist")
+	return res, nil
 }
 
 /**
@@ -315,14 +657,22 @@ func (c *FileCache) HGet(key, field string) (res string, err error) {
  * @param {interface{}} data
  * @return {*}
  */
-func (c *FileCache) HSet(key string, data interface{}) (err error) {
+func (c *FileCache) HSetCtx(ctx context.Context, key string, data interface{}) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
 
-	if reflect.TypeOf(data).Kind() == reflect.Map {
-		c.Set(key, data, 0)
-	} else {
+	if reflect.TypeOf(data).Kind() != reflect.Map {
 		return errors.New("data must be map")
 	}
-	return nil
+	return c.locked(key, func() error {
+		return c.updateHash(key, func(fields map[string]interface{}) {
+			iter := reflect.ValueOf(data).MapRange()
+			for iter.Next() {
+				fields[ToStr(iter.Key().Interface())] = iter.Value().Interface()
+			}
+		})
+	})
 }
 
 /**
@@ -331,16 +681,15 @@ func (c *FileCache) HSet(key string, data interface{}) (err error) {
  * @param {string} field
  * @return {*}
  */
-func (c *FileCache) HDel(key, field string) (err error) {
-	if !c.Exists(key) {
-		return nil
-	}
-	data, err := c.HGetAll(key)
-	if err == nil {
-		delete(data, field)
-		return c.Set(key, data, 0)
+func (c *FileCache) HDelCtx(ctx context.Context, key, field string) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	return nil
+	return c.locked(key, func() error {
+		return c.updateHash(key, func(fields map[string]interface{}) {
+			delete(fields, field)
+		})
+	})
 }
 
 /**
@@ -348,41 +697,99 @@ func (c *FileCache) HDel(key, field string) (err error) {
  * @param 存入hash的key值
  * @return {*}
  */
-func (c *FileCache) HGetAll(key string) (data map[string]string, err error) {
-	if !c.Exists(key) {
-		return data, errors.New("is empty")
+func (c *FileCache) HGetAllCtx(ctx context.Context, key string) (data map[string]string, err error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
+	item, err := c.read(key)
+	if err != nil {
+		return data, err
 	}
-	newData, err := c.Get(key)
+	fields, err := c.hash(key, item)
 	if err != nil {
-		return data, errors.New("Get failed")
+		return data, err
 	}
-	if newData != nil {
-		data = make(map[string]string)
-		newVal := newData.(map[string]interface{})
-		for i, v := range newVal {
-			data[i] = ToStr(v)
-		}
+	data = make(map[string]string)
+	for i, v := range fields {
+		data[i] = ToStr(v)
 	}
 	return data, nil
 }
 
+// hash returns the fields of item, the hash stored at key.
+func (c *FileCache) hash(key string, item *Item) (map[string]interface{}, error) {
+	raw, ok := item.Val.([]byte)
+	if !ok || !item.Hash {
+		return nil, errNotHash(key)
+	}
+	if c.fields != nil {
+		plain, err := c.fields.open(string(raw))
+		if err != nil {
+			return nil, errNotHash(key)
+		}
+		raw = []byte(plain)
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(raw, &fields) != nil || fields == nil {
+		return nil, errNotHash(key)
+	}
+	return fields, nil
+}
+
+// updateHash applies fn to the fields of the hash at key, an empty one if it
+// is missing, keeping its expiration. The key is removed once it has no
+// fields left. Hashes are stored as JSON whatever the codec, encrypted when
+// encryption keys are configured. The caller must hold the lock of key.
+func (c *FileCache) updateHash(key string, fn func(fields map[string]interface{})) error {
+	item, err := c.read(key)
+	fields := make(map[string]interface{})
+	if errors.Is(err, ErrNotFound) {
+		item = &Item{Created: time.Now().Unix(), Hash: true}
+	} else if err != nil {
+		return err
+	} else if fields, err = c.hash(key, item); err != nil {
+		return err
+	}
+	fn(fields)
+	if len(fields) == 0 {
+		return c.remove(key)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if c.fields != nil {
+		sealed, err := c.fields.seal(string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(sealed)
+	}
+	item.Val = data
+	return c.write(key, item)
+}
+
 /**
  * @desc: 设置有效期
  * @param {string} key
  * @param {time.Duration} expire
  * @return {*}
  */
-func (c *FileCache) Expire(key string, expire time.Duration) error {
-	if !c.Exists(key) {
-		return errors.New("key does not exist")
+func (c *FileCache) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	data, err := c.Get(key)
-	if err == nil {
-		if err := c.Set(key, data, int64(expire)); err == nil {
-			return nil
+	return c.locked(key, func() error {
+		item, err := c.read(key)
+		if err != nil {
+			return err
 		}
-	}
-	return err
+		if expire <= 0 {
+			return c.remove(key)
+		}
+		item.setExpire(expire)
+		return c.write(key, item)
+	})
 }
 
 /**
@@ -390,50 +797,216 @@ func (c *FileCache) Expire(key string, expire time.Duration) error {
  * @param {*} bucket
  * @return {*}
  */
-func (c *FileCache) Clear(key string) (err error) {
-	if !strings.Contains(key, "_") {
-		return os.RemoveAll(c.rootPath + "/" + key)
+func (c *FileCache) ClearCtx(ctx context.Context, prefix string) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	if len(prefix) == 0 {
+		return c.removeAll()
+	}
+	buckets, err := c.buckets(prefix)
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets {
+		// Every key of the bucket matches, unindexed ones included. Single
+		// hex digit directories may hold keys of the root bucket instead.
+		if len(bucket) > 0 && !isHashDir(bucket) && strings.HasPrefix(bucket+"_", prefix) {
+			if err := os.RemoveAll(filepath.Join(c.rootPath, bucket)); err != nil {
+				return err
+			}
+			continue
+		}
+		keys, err := c.indexedKeys(bucket)
+		if err != nil {
+			return err
+		}
+		for key := range keys {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := c.locked(key, func() error { return c.remove(key) }); err != nil {
+				return err
+			}
+		}
 	}
-	return os.Remove(c.filepath(key))
+	return nil
 }
 
-func (c *FileCache) Size(bucket string) string {
-	var size uint64
-	filepath.Walk(bucket, func(_ string, info os.FileInfo, err error) error {
-		if info == nil {
-			return nil
+// SizeCtx returns the number of bytes of the files of the keys starting
+// with bucket.
+func (c *FileCache) SizeCtx(ctx context.Context, bucket string) string {
+	if c.check(ctx) != nil {
+		return "0"
+	}
+	keys, err := c.keys(bucket)
+	if err != nil {
+		return "0"
+	}
+	var size int64
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return "0"
 		}
-		if !info.IsDir() {
-			size += uint64(info.Size())
+		if info, err := os.Stat(c.filepath(key)); err == nil {
+			size += info.Size()
 		}
-		return nil
-	})
+	}
 	return fmt.Sprintf("%d", size)
 }
 
-func (c *FileCache) TTL(key string) (res time.Duration) {
-	return res
+// TTLCtx returns the remaining time to live of key, -1 if it has no expiration
+// and -2 if it does not exist.
+func (c *FileCache) TTLCtx(ctx context.Context, key string) time.Duration {
+	if c.check(ctx) != nil {
+		return -2
+	}
+	item, err := c.read(key)
+	if err != nil {
+		return -2
+	}
+	return item.ttl()
 }
 
-func (c *FileCache) Type(key string) (res string) {
-	return res
+// TypeCtx returns the type of value stored at key: "string", "hash" or
+// "none". Hashes written by older versions are reported as strings.
+func (c *FileCache) TypeCtx(ctx context.Context, key string) string {
+	if c.check(ctx) != nil {
+		return "none"
+	}
+	item, err := c.read(key)
+	if err != nil {
+		return "none"
+	}
+	return item.kind()
 }
 
-func (c *FileCache) Search(bucket string) []string {
-	// 获取所有键
-	var keys []string
-	filepath.Walk(bucket, func(_ string, info os.FileInfo, err error) error {
-		if info == nil {
-			return nil
+// SearchCtx returns the keys starting with bucket, or matching it as a glob
+// pattern like the memory adapter, from the bucket indexes.
+func (c *FileCache) SearchCtx(ctx context.Context, bucket string) []string {
+	if c.check(ctx) != nil {
+		return []string{}
+	}
+	// Only the part before the first wildcard narrows down the buckets.
+	prefix := bucket
+	if i := strings.IndexAny(bucket, `*?[\`); i >= 0 {
+		prefix = bucket[:i]
+	}
+	candidates, err := c.keys(prefix)
+	if err != nil {
+		return []string{}
+	}
+	keys := []string{}
+	for _, key := range candidates {
+		if ctx.Err() != nil {
+			return []string{}
 		}
-		if !info.IsDir() {
-			keys = append(keys, info.Name())
+		if !matchKey(bucket, key) {
+			continue
 		}
-		return nil
-	})
+		// Skip the keys whose file expired or was removed behind the index.
+		if _, err := c.read(key); err == nil {
+			keys = append(keys, key)
+		}
+	}
 	return keys
 }
 
+// Cache implementation, every method runs its Ctx variant without deadline.
+
+func (c *FileCache) Set(key string, val interface{}, expire int64) error {
+	return c.SetCtx(context.Background(), key, val, expire)
+}
+
+func (c *FileCache) SetEx(key string, val interface{}, expire time.Duration) error {
+	return c.SetExCtx(context.Background(), key, val, expire)
+}
+
+func (c *FileCache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *FileCache) Del(key string) error {
+	return c.DelCtx(context.Background(), key)
+}
+
+func (c *FileCache) Incr(key string) error {
+	return c.IncrCtx(context.Background(), key)
+}
+
+func (c *FileCache) Decr(key string) error {
+	return c.DecrCtx(context.Background(), key)
+}
+
+func (c *FileCache) Exists(key string) bool {
+	return c.ExistsCtx(context.Background(), key)
+}
+
+func (c *FileCache) Flush() error {
+	return c.FlushCtx(context.Background())
+}
+
+func (c *FileCache) HMSet(key string, data interface{}) error {
+	return c.HMSetCtx(context.Background(), key, data)
+}
+
+func (c *FileCache) HMGet(key string, fields []string) (res map[string]string, err error) {
+	return c.HMGetCtx(context.Background(), key, fields)
+}
+
+func (c *FileCache) HGet(key, field string) (res string, err error) {
+	return c.HGetCtx(context.Background(), key, field)
+}
+
+func (c *FileCache) HSet(key string, data interface{}) (err error) {
+	return c.HSetCtx(context.Background(), key, data)
+}
+
+func (c *FileCache) HDel(key, field string) (err error) {
+	return c.HDelCtx(context.Background(), key, field)
+}
+
+func (c *FileCache) HGetAll(key string) (data map[string]string, err error) {
+	return c.HGetAllCtx(context.Background(), key)
+}
+
+func (c *FileCache) Expire(key string, expire time.Duration) error {
+	return c.ExpireCtx(context.Background(), key, expire)
+}
+
+func (c *FileCache) Clear(key string) (err error) {
+	return c.ClearCtx(context.Background(), key)
+}
+
+func (c *FileCache) Size(bucket string) string {
+	return c.SizeCtx(context.Background(), bucket)
+}
+
+func (c *FileCache) TTL(key string) time.Duration {
+	return c.TTLCtx(context.Background(), key)
+}
+
+func (c *FileCache) Type(key string) (res string) {
+	return c.TypeCtx(context.Background(), key)
+}
+
+func (c *FileCache) Search(bucket string) []string {
+	return c.SearchCtx(context.Background(), bucket)
+}
+
+func (c *FileCache) Scan(prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	return c.ScanCtx(context.Background(), prefix, cursor, limit)
+}
+
 func init() {
-	Register("file", NewFileCache())
+	RegisterFactory("file", func(opt Options) (Cache, error) {
+		c := NewFileCache()
+		if err := c.StartAndGC(opt); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
 }
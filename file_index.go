@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// fileIndexName is the name of the file listing the keys stored in a bucket
+// directory. It is a log of lines adding or removing a key, "+" or "-"
+// followed by the quoted key, appended to by every process sharing the root
+// and rewritten with the live keys only by the GC.
+const fileIndexName = ".index"
+
+// bucket returns the bucket of key, the part before its first "_", or "" for
+// keys stored at the root.
+func (c *FileCache) bucket(key string) string {
+	if i := strings.Index(key, "_"); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// isHashDir reports whether a directory of the root may be one of the
+// directories keys of the root bucket are spread over.
+func isHashDir(name string) bool {
+	return len(name) == 1 && strings.Contains("0123456789abcdef", name)
+}
+
+// indexPath returns the index file of bucket.
+func (c *FileCache) indexPath(bucket string) string {
+	return filepath.Join(c.rootPath, bucket, fileIndexName)
+}
+
+// index records in the index of its bucket that key was added or removed.
+func (c *FileCache) index(key string, added bool) error {
+	op := "-"
+	if added {
+		op = "+"
+	}
+	filename := c.indexPath(c.bucket(key))
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	unlock, err := c.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	// A single write keeps the lines of concurrent writers whole.
+	_, err = f.WriteString(op + strconv.Quote(key) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// indexedKeys returns the keys recorded in the index of bucket. Keys whose
+// file was removed without going through the cache may still be listed.
+func (c *FileCache) indexedKeys(bucket string) (map[string]struct{}, error) {
+	keys, _, _, err := c.readIndex(bucket)
+	return keys, err
+}
+
+// readIndex replays the index of bucket. It also returns the number of
+// lines and of bytes read.
+func (c *FileCache) readIndex(bucket string) (keys map[string]struct{}, lines int, size int64, err error) {
+	keys = make(map[string]struct{})
+	f, err := os.Open(c.indexPath(bucket))
+	if os.IsNotExist(err) {
+		return keys, 0, 0, nil
+	} else if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// A line still being appended is left for the next read.
+			return keys, lines, size, nil
+		} else if err != nil {
+			return nil, 0, 0, err
+		}
+		size += int64(len(line))
+		lines++
+		key, err := strconv.Unquote(strings.TrimSuffix(line[1:], "\n"))
+		if err != nil {
+			continue
+		}
+		switch line[0] {
+		case '+':
+			keys[key] = struct{}{}
+		case '-':
+			delete(keys, key)
+		}
+	}
+}
+
+// buckets returns the buckets that may hold keys starting with prefix.
+func (c *FileCache) buckets(prefix string) ([]string, error) {
+	if i := strings.Index(prefix, "_"); i >= 0 {
+		return []string{prefix[:i]}, nil
+	}
+	entries, err := os.ReadDir(c.rootPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	buckets := []string{""}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && !strings.HasPrefix(name, ".") && strings.HasPrefix(name, prefix) {
+			buckets = append(buckets, name)
+		}
+	}
+	return buckets, nil
+}
+
+// compactIndexes rewrites the indexes of every bucket, see compactIndex.
+func (c *FileCache) compactIndexes() {
+	buckets, err := c.buckets("")
+	if err != nil {
+		log.Printf("error compacting cache indexes: %v", err)
+		return
+	}
+	for _, bucket := range buckets {
+		if err := c.compactIndex(bucket); err != nil {
+			log.Printf("error compacting cache index of bucket '%s': %v", bucket, err)
+		}
+	}
+}
+
+// compactIndex rewrites the index of bucket with a line per key whose file
+// still exists, once removed keys make up half of its lines.
+func (c *FileCache) compactIndex(bucket string) error {
+	keys, lines, size, err := c.readIndex(bucket)
+	if err != nil || lines == 0 {
+		return err
+	}
+	live := make([]string, 0, len(keys))
+	for key := range keys {
+		if _, err := os.Stat(c.filepath(key)); err == nil {
+			live = append(live, key)
+		}
+	}
+	if lines < 2*len(live) {
+		return nil
+	}
+	sort.Strings(live)
+	var buf bytes.Buffer
+	for _, key := range live {
+		buf.WriteString("+" + strconv.Quote(key) + "\n")
+	}
+
+	filename := c.indexPath(bucket)
+	unlock, err := c.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// Keep the lines appended since the index was read.
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(size, io.SeekStart)
+	if err == nil {
+		_, err = buf.ReadFrom(f)
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return c.writeFile(filename, buf.Bytes())
+}
+
+// ScanCtx lists the keys starting with prefix in lexical order from the
+// bucket indexes, see Cache.Scan. The indexes are read when a walk starts,
+// see scanSnapshots.
+func (c *FileCache) ScanCtx(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, "", err
+	}
+	keys, err := c.scans.after(prefix, cursor, func() ([]string, error) {
+		return c.keys(prefix)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	infos, next := pageKeys(keys, scanLimit(limit), func(key string) (KeyInfo, bool) {
+		if ctx.Err() != nil {
+			return KeyInfo{}, false
+		}
+		item, err := c.read(key)
+		if err != nil {
+			return KeyInfo{}, false
+		}
+		return KeyInfo{Key: key, TTL: item.ttl(), Type: item.kind()}, true
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return infos, next, nil
+}
+
+// keys returns the indexed keys starting with prefix.
+func (c *FileCache) keys(prefix string) ([]string, error) {
+	buckets, err := c.buckets(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, bucket := range buckets {
+		indexed, err := c.indexedKeys(bucket)
+		if err != nil {
+			return nil, err
+		}
+		for key := range indexed {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// fileExport is a line written by Export.
+type fileExport struct {
+	Key    string      `json:"key"`
+	Expire int64       `json:"expire"` // remaining seconds, 0 without expiration
+	Value  interface{} `json:"value"`
+}
+
+// Export writes the keys starting with prefix to w in lexical order, one JSON
+// object per line with the key, the remaining seconds before it expires, 0
+// if it does not, and the value as returned by Get.
+func (c *FileCache) Export(ctx context.Context, w io.Writer, prefix string) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	keys, err := c.keys(prefix)
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	enc := json.NewEncoder(w)
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		item, err := c.read(key)
+		if err != nil {
+			continue
+		}
+		ttl := item.ttl()
+		val, err := c.value(item)
+		if err != nil {
+			return err
+		}
+		line := fileExport{Key: key, Value: val}
+		if ttl > 0 {
+			line.Expire = expireSeconds(ttl)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// fileLockStripes is the number of locks the keys of a file cache share.
+	fileLockStripes = 64
+	// fileLockDir is the directory of the root holding the lock files.
+	fileLockDir = ".locks"
+	// fileIndexLock is the lock file guarding the bucket indexes.
+	fileIndexLock = "index"
+)
+
+// lockKey locks key against the writers of this process and, where flock is
+// available, of the other processes sharing the root. Keys are spread over
+// fileLockStripes locks, so unrelated keys may wait on each other.
+func (c *FileCache) lockKey(key string) (unlock func(), err error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	n := h.Sum32() % fileLockStripes
+
+	c.locks[n].Lock()
+	unlockFile, err := c.lockFile(strconv.Itoa(int(n)))
+	if err != nil {
+		c.locks[n].Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		c.locks[n].Unlock()
+	}, nil
+}
+
+// locked runs fn holding the lock of key, see lockKey.
+func (c *FileCache) locked(key string, fn func() error) error {
+	unlock, err := c.lockKey(key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// lockIndex locks the bucket indexes against the other writers, so
+// compactions do not drop the keys appended meanwhile.
+func (c *FileCache) lockIndex() (unlock func(), err error) {
+	c.idxLock.Lock()
+	unlockFile, err := c.lockFile(fileIndexLock)
+	if err != nil {
+		c.idxLock.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		c.idxLock.Unlock()
+	}, nil
+}
+
+// lockFile takes the advisory lock of the lock file name. The caller must
+// hold the in-process lock matching it.
+func (c *FileCache) lockFile(name string) (unlock func(), err error) {
+	dir := filepath.Join(c.rootPath, fileLockDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		funlock(f)
+		f.Close()
+	}, nil
+}
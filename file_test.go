@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSearchAndSize(t *testing.T) {
+	c := startTestCache(t, NewFileCache(), Options{AdapterConfig: filepath.Join(t.TempDir(), "cache")})
+	for _, key := range []string{"user_1", "user_2", "user_10", "post_1", "plain"} {
+		if err := c.Set(key, "value", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Del("user_2")
+
+	for pattern, want := range map[string][]string{
+		"user_":   {"user_1", "user_10"},
+		"user_*0": {"user_10"},
+		"p":       {"plain", "post_1"},
+		"missing": {},
+		"":        {"plain", "post_1", "user_1", "user_10"},
+	} {
+		got := c.Search(pattern)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+
+	size := func(bucket string) int {
+		n, err := strconv.Atoi(c.Size(bucket))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	users, all := size("user_"), size("")
+	if users == 0 || users >= all {
+		t.Errorf("Size(user_) = %d, Size() = %d", users, all)
+	}
+	if n := size("missing"); n != 0 {
+		t.Errorf("Size(missing) = %d, want 0", n)
+	}
+}
+
+func TestParseFileConfig(t *testing.T) {
+	for _, tc := range []struct {
+		config           string
+		path             string
+		syncDir, keepBad bool
+	}{
+		{"", "cache", false, false},
+		{"runtime/cache", "runtime/cache", false, false},
+		{"path=data,sync_dir=true,corrupt=quarantine", "data", true, true},
+		{"sync_dir=false,corrupt=delete", "cache", false, false},
+	} {
+		path, syncDir, keepBad, err := parseFileConfig(tc.config)
+		if err != nil || path != tc.path || syncDir != tc.syncDir || keepBad != tc.keepBad {
+			t.Errorf("%q: got %q, %v, %v, %v", tc.config, path, syncDir, keepBad, err)
+		}
+	}
+	for _, config := range []string{"sync_dir=maybe", "corrupt=keep", "path=data,unknown=1"} {
+		if _, _, _, err := parseFileConfig(config); err == nil || !strings.Contains(err.Error(), "cache/file:") {
+			t.Errorf("%q: err = %v, want an error", config, err)
+		}
+	}
+}
+
+func TestFileWriteLeavesNoTemp(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "cache")
+	c := startTestCache(t, NewFileCache(), Options{AdapterConfig: "path=" + root + ",sync_dir=true"}).(*FileCache)
+	for i := 0; i < 3; i++ {
+		if err := c.Set("user_1", i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A failing rename removes the temporary file.
+	dir := filepath.Join(root, "blocked")
+	if err := os.MkdirAll(filepath.Join(dir, "file", "child"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeFile(filepath.Join(dir, "file"), []byte("data")); err == nil {
+		t.Error("writeFile replaced a directory")
+	}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasPrefix(info.Name(), fileTempPrefix) {
+			t.Errorf("temporary file left: %s", path)
+		}
+		return nil
+	})
+}
+
+func TestFileCollectCorrupt(t *testing.T) {
+	for _, mode := range []string{"delete", "quarantine"} {
+		root := filepath.Join(t.TempDir(), "cache")
+		c := startTestCache(t, NewFileCache(), Options{AdapterConfig: "path=" + root + ",corrupt=" + mode}).(*FileCache)
+		for _, key := range []string{"user_good", "user_garbage", "user_truncated"} {
+			if err := c.Set(key, "value of "+key, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(c.filepath("user_garbage"), []byte("not gob"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(c.filepath("user_truncated"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(c.filepath("user_truncated"), data[:len(data)/2], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// An item expired long ago, removed by the same sweep.
+		if err := c.write("user_expired", &Item{Val: "v", Created: time.Now().Unix() - 60, Expire: 1}); err != nil {
+			t.Fatal(err)
+		}
+		// Temporary files of crashed writes are removed once old.
+		dir := filepath.Dir(c.filepath("user_good"))
+		stale, fresh := filepath.Join(dir, fileTempPrefix+"stale"), filepath.Join(dir, fileTempPrefix+"fresh")
+		for _, name := range []string{stale, fresh} {
+			if err := os.WriteFile(name, []byte("partial"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		old := time.Now().Add(-2 * fileTempMaxAge)
+		if err := os.Chtimes(stale, old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		c.collect()
+
+		for _, key := range []string{"user_garbage", "user_truncated", "user_expired"} {
+			if _, err := os.Stat(c.filepath(key)); !os.IsNotExist(err) {
+				t.Errorf("%s: %s still there after the GC", mode, key)
+			}
+		}
+		if val, err := c.Get("user_good"); err != nil || val != "value of user_good" {
+			t.Errorf("%s: Get = %v, %v", mode, val, err)
+		}
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Errorf("%s: stale temporary file kept", mode)
+		}
+		if _, err := os.Stat(fresh); err != nil {
+			t.Errorf("%s: fresh temporary file removed: %v", mode, err)
+		}
+		quarantined, _ := os.ReadDir(filepath.Join(root, fileQuarantineDir))
+		want := 0
+		if mode == "quarantine" {
+			want = 2
+		}
+		if len(quarantined) != want {
+			t.Errorf("%s: %d files quarantined, want %d", mode, len(quarantined), want)
+		}
+	}
+}
+
+func TestFileLockAcrossInstances(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		t.Skip("flock is unavailable, only the writers of one instance are serialized")
+	}
+	// Two caches sharing a root stand for two processes: each has its own
+	// in-process locks, so only the lock files serialize them.
+	root := filepath.Join(t.TempDir(), "cache")
+	caches := []Cache{
+		startTestCache(t, NewFileCache(), Options{AdapterConfig: root}),
+		startTestCache(t, NewFileCache(), Options{AdapterConfig: root}),
+	}
+	if err := caches[0].Set("user_count", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, rounds = 4, 25
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		c := caches[i%len(caches)]
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if err := c.Incr("user_count"); err != nil {
+					t.Error(err)
+					return
+				}
+				field := "f" + strconv.Itoa(i) + "_" + strconv.Itoa(j)
+				if err := c.HSet("user_fields", map[string]string{field: "1", "tmp" + field: "1"}); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := c.HDel("user_fields", "tmp"+field); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if val, err := caches[1].Get("user_count"); err != nil || val != strconv.Itoa(workers*rounds) {
+		t.Errorf("count = %v, %v, want %d", val, err, workers*rounds)
+	}
+	fields, err := caches[1].HGetAll("user_fields")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != workers*rounds {
+		t.Errorf("%d fields, want %d", len(fields), workers*rounds)
+	}
+	for i := 0; i < workers; i++ {
+		for j := 0; j < rounds; j++ {
+			field := "f" + strconv.Itoa(i) + "_" + strconv.Itoa(j)
+			if _, ok := fields[field]; !ok {
+				t.Errorf("field %s lost", field)
+			}
+		}
+	}
+}
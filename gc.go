@@ -0,0 +1,39 @@
+package cache
+
+import "time"
+
+// gcLoop is a garbage collection routine started by runGC.
+type gcLoop struct {
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// runGC calls fn right away and then once every interval in its own
+// goroutine, until the returned loop is stopped.
+func runGC(interval time.Duration, fn func()) *gcLoop {
+	l := &gcLoop{stopCh: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			fn()
+			select {
+			case <-l.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return l
+}
+
+// stop ends the loop and waits for a running collection to finish.
+// Stopping a nil loop does nothing.
+func (l *gcLoop) stop() {
+	if l == nil {
+		return
+	}
+	close(l.stopCh)
+	<-l.done
+}
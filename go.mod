@@ -5,6 +5,8 @@ go 1.20
 require (
 	github.com/dgraph-io/badger/v3 v3.2103.5
 	github.com/goccy/go-json v0.10.3
+	github.com/golang/snappy v0.0.3
+	github.com/klauspost/compress v1.12.3
 	github.com/platship/go-utils v1.0.0
 	github.com/redis/go-redis/v9 v9.5.1
 	gopkg.in/ini.v1 v1.67.0
@@ -23,9 +25,7 @@ require (
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b // indirect
 	github.com/golang/groupcache v0.0.0-20190702054246-869f871628b6 // indirect
 	github.com/golang/protobuf v1.3.1 // indirect
-	github.com/golang/snappy v0.0.3 // indirect
 	github.com/google/flatbuffers v1.12.1 // indirect
-	github.com/klauspost/compress v1.12.3 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	go.opencensus.io v0.22.5 // indirect
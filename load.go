@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// errLoaderPanic is returned to the callers waiting on a loader that panicked.
+var errLoaderPanic = errors.New("cache: loader panicked")
+
+// loadTimeout bounds a shared load. Loads run on their own context rather
+// than on the context of the caller starting them, so that caller giving up
+// does not fail the others waiting for the same key.
+const loadTimeout = time.Minute
+
+// flight is a loader call in progress, done is closed once it returns.
+type flight struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// flightGroup collapses concurrent calls for the same key into one.
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[interface{}]*flight
+}
+
+// do calls fn once for concurrent callers of the same key and hands every
+// caller its result. fn runs in its own goroutine on a context detached from
+// ctx, see detach, and every caller stops waiting when its own ctx is done.
+func (g *flightGroup) do(ctx context.Context, key interface{}, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	f := g.start(ctx, key, fn)
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// goDo calls fn like do unless a call for key is in progress, without
+// waiting for it.
+func (g *flightGroup) goDo(ctx context.Context, key interface{}, fn func(context.Context) (interface{}, error)) {
+	g.start(ctx, key, fn)
+}
+
+// start returns the flight of key, calling fn in a new goroutine when there
+// is none in progress.
+func (g *flightGroup) start(ctx context.Context, key interface{}, fn func(context.Context) (interface{}, error)) *flight {
+	f, leader := g.join(key)
+	if !leader {
+		return f
+	}
+	go func() {
+		ctx, cancel := detach(ctx)
+		defer cancel()
+		defer g.finish(key, f)
+		defer func() {
+			if r := recover(); r != nil {
+				f.val, f.err = nil, fmt.Errorf("%w: %v", errLoaderPanic, r)
+				log.Printf("%v\n%s", f.err, debug.Stack())
+			}
+		}()
+		f.val, f.err = fn(ctx)
+	}()
+	return f
+}
+
+// join returns the flight of key, leader is true when the caller started it
+// and has to finish it.
+func (g *flightGroup) join(key interface{}) (f *flight, leader bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.flights == nil {
+		g.flights = make(map[interface{}]*flight)
+	}
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+	f = &flight{done: make(chan struct{}), err: errLoaderPanic}
+	g.flights[key] = f
+	return f, true
+}
+
+func (g *flightGroup) finish(key interface{}, f *flight) {
+	g.lock.Lock()
+	delete(g.flights, key)
+	g.lock.Unlock()
+	close(f.done)
+}
+
+// detach returns a context with the values of ctx but not its deadline or
+// cancellation, which times out after loadTimeout instead.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{ctx}, loadTimeout)
+}
+
+// detachedContext keeps the values of a context and drops the rest.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+var loads flightGroup
+
+// loadKey identifies a key of one cache in the loads group.
+type loadKey struct {
+	cache Cache
+	key   string
+}
+
+// GetOrLoad gets cached value by given key. On a miss it calls loader, stores
+// its value with expire duration and returns it. Concurrent misses for the
+// same key of the same cache share a single loader call.
+func GetOrLoad(c Cache, key string, expire time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	return GetOrLoadCtx(context.Background(), c, key, expire, func(context.Context) (interface{}, error) {
+		return loader()
+	})
+}
+
+// GetOrLoadCtx is GetOrLoad with a context, passed to the adapter when it
+// implements CacheContext. Only ErrNotFound counts as a miss, other errors of
+// the cache are returned. When storing the loaded value fails, the value is
+// returned together with the error.
+//
+// The shared load gets a context with the values of ctx that times out after
+// a minute, it keeps going when ctx is done so the other callers still get
+// its value, ctx only bounds how long this caller waits for it.
+func GetOrLoadCtx(ctx context.Context, c Cache, key string, expire time.Duration, loader func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	val, err := getCtx(ctx, c, key)
+	if !errors.Is(err, ErrNotFound) {
+		return val, err
+	}
+
+	load := func(ctx context.Context) (interface{}, error) {
+		val, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return val, setExCtx(ctx, c, key, val, expire)
+	}
+	// Caches that cannot be map keys load without sharing.
+	if !reflect.TypeOf(c).Comparable() {
+		return load(ctx)
+	}
+	return loads.do(ctx, loadKey{c, key}, load)
+}
+
+func getCtx(ctx context.Context, c Cache, key string) (interface{}, error) {
+	if cc, ok := c.(CacheContext); ok {
+		return cc.GetCtx(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+func setExCtx(ctx context.Context, c Cache, key string, val interface{}, expire time.Duration) error {
+	if cc, ok := c.(CacheContext); ok {
+		return cc.SetExCtx(ctx, key, val, expire)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetEx(key, val, expire)
+}
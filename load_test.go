@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadLeaderDeadline(t *testing.T) {
+	c := startTestCache(t, NewMemoryCache(), Options{})
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		calls.Add(1)
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return "loaded", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// The first caller starts the load and gives up before it returns.
+	leaderCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leader := make(chan error, 1)
+	go func() {
+		_, err := GetOrLoadCtx(leaderCtx, c, "key", time.Minute, loader)
+		leader <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	val, err := GetOrLoadCtx(context.Background(), c, "key", time.Minute, loader)
+	if err != nil || val != "loaded" {
+		t.Fatalf("follower got %v, %v, want the loaded value", val, err)
+	}
+	if err := <-leader; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("leader got %v, want its own deadline", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+	if val, err := c.Get("key"); err != nil || val != "loaded" {
+		t.Errorf("cached %v, %v", val, err)
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	c := startTestCache(t, NewMemoryCache(), Options{})
+	_, err := GetOrLoad(c, "key", time.Minute, func() (interface{}, error) {
+		panic("boom")
+	})
+	if !errors.Is(err, errLoaderPanic) {
+		t.Errorf("err = %v, want errLoaderPanic", err)
+	}
+}
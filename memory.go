@@ -0,0 +1,842 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// defaultMemoryShards is the number of lock stripes of a memory cache.
+const defaultMemoryShards = 16
+
+// MemoryStats reports the occupancy and eviction counters of a memory cache.
+type MemoryStats struct {
+	Entries    int
+	Bytes      int64
+	Evictions  uint64 // entries dropped to stay within the limits
+	Rejections uint64 // new entries refused by the admission policy
+}
+
+// MemoryCache represents a memory cache adapter implementation.
+// Keys are spread across shards that each have their own lock, so concurrent
+// access scales across cores. It is unbounded unless max_entries or max_bytes
+// is configured, in which case every shard evicts its share of the entries
+// according to the configured policy.
+type MemoryCache struct {
+	lock    sync.Mutex   // guards the configuration below
+	shards  atomic.Value // []*memoryShard, its length is a power of two
+	gc      *gcLoop
+	closed  atomic.Bool
+	codec   Codec        // encodes the values when set, otherwise they are kept as is
+	fields  *fieldCipher // encrypts the values of hash fields when set
+	onEvict func(key string, val interface{})
+	scans   scanSnapshots
+}
+
+// NewMemoryCache creates and returns a new memory cacher.
+func NewMemoryCache() *MemoryCache {
+	c := &MemoryCache{}
+	shards := make([]*memoryShard, defaultMemoryShards)
+	for i := range shards {
+		shards[i], _ = newMemoryShard(0, 0, "")
+	}
+	c.shards.Store(shards)
+	return c
+}
+
+func (c *MemoryCache) allShards() []*memoryShard {
+	return c.shards.Load().([]*memoryShard)
+}
+
+// shard returns the shard owning key.
+func (c *MemoryCache) shard(key string) *memoryShard {
+	shards := c.allShards()
+	return shards[shardIndex(key, uint32(len(shards)-1))]
+}
+
+// notify reports evicted entries to the OnEvict callback, it must be called
+// without holding a shard lock so the callback may use the cache.
+func (c *MemoryCache) notify(evicted []memoryEntry) {
+	if len(evicted) == 0 {
+		return
+	}
+	c.lock.Lock()
+	fn := c.onEvict
+	c.lock.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, e := range evicted {
+		fn(e.key, e.val)
+	}
+}
+
+// OnEvict registers fn to be called for every entry evicted to respect the
+// limits, and for every new entry the tinylfu policy refuses to admit.
+func (c *MemoryCache) OnEvict(fn func(key string, val interface{})) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onEvict = fn
+}
+
+// Stats returns the current occupancy and eviction counters.
+func (c *MemoryCache) Stats() (stats MemoryStats) {
+	for _, s := range c.allShards() {
+		s.lock.RLock()
+		stats.Entries += len(s.items)
+		stats.Bytes += s.bytes
+		stats.Evictions += s.evictions
+		stats.Rejections += s.rejections
+		s.lock.RUnlock()
+	}
+	return stats
+}
+
+// SetCtx puts value into cache with key and expire time in seconds.
+// If expired is 0, it lives forever.
+func (c *MemoryCache) SetCtx(ctx context.Context, key string, val interface{}, expire int64) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	return c.SetExCtx(ctx, key, val, time.Duration(expire)*time.Second)
+}
+
+// SetExCtx puts value into cache with key and expire duration. With the
+// tinylfu policy a new key may be refused when the cache is full: Set then
+// returns nil but stores nothing, the entry is passed to OnEvict and counted
+// in Stats().Rejections.
+func (c *MemoryCache) SetExCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	if c.codec != nil {
+		data, err := c.codec.Marshal(val)
+		if err != nil {
+			return err
+		}
+		val = data
+	}
+	item := &memoryItem{val: val}
+	if expire > 0 {
+		item.expire = time.Now().Add(expire).UnixNano()
+	}
+
+	s := c.shard(key)
+	s.lock.Lock()
+	evicted, err := s.store(key, item)
+	s.lock.Unlock()
+
+	c.notify(evicted)
+	return err
+}
+
+// GetCtx gets cached value by given key.
+func (c *MemoryCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
+	s := c.shard(key)
+	unlock := s.lockRead()
+	defer unlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return nil, errNotFound(key)
+	}
+	if item.hash != nil {
+		return nil, errIsHash(key)
+	}
+	if data, ok := item.val.([]byte); ok && c.codec != nil {
+		return decodeWith(c.codec, data)
+	}
+	return item.val, nil
+}
+
+// DelCtx deletes cached value by given key.
+func (c *MemoryCache) DelCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.remove(key)
+	return nil
+}
+
+// IncrCtx increases cached int-type value by given key as a counter.
+func (c *MemoryCache) IncrCtx(ctx context.Context, key string) (err error) {
+	return c.counter(ctx, key, Incr)
+}
+
+// DecrCtx decreases cached int-type value by given key as a counter.
+func (c *MemoryCache) DecrCtx(ctx context.Context, key string) (err error) {
+	return c.counter(ctx, key, Decr)
+}
+
+// counter replaces the value of key by fn applied to it.
+func (c *MemoryCache) counter(ctx context.Context, key string, fn func(interface{}) (interface{}, error)) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return errNotFound(key)
+	}
+	if item.hash != nil {
+		return errIsHash(key)
+	}
+	s.update(key, item, func() {
+		if data, ok := item.val.([]byte); ok && c.codec != nil {
+			item.val, err = encodeCounter(c.codec, data, fn)
+			return
+		}
+		item.val, err = fn(item.val)
+	})
+	return err
+}
+
+// ExistsCtx returns true if cached value exists.
+func (c *MemoryCache) ExistsCtx(ctx context.Context, key string) bool {
+	if c.check(ctx) != nil {
+		return false
+	}
+	s := c.shard(key)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_, ok := s.get(key)
+	return ok
+}
+
+// FlushCtx deletes all cached data.
+func (c *MemoryCache) FlushCtx(ctx context.Context) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	for _, s := range c.allShards() {
+		s.lock.Lock()
+		s.flush()
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// collect drops the expired items of every shard.
+func (c *MemoryCache) collect() {
+	now := time.Now().UnixNano()
+	for _, s := range c.allShards() {
+		s.gc(now)
+	}
+}
+
+// check returns the error of ctx, or ErrClosed once the cache is closed.
+func (c *MemoryCache) check(ctx context.Context) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	return ctx.Err()
+}
+
+// Close stops the GC routine and drops every item.
+func (c *MemoryCache) Close() error {
+	c.lock.Lock()
+	if c.closed.Load() {
+		c.lock.Unlock()
+		return ErrClosed
+	}
+	c.closed.Store(true)
+	gc := c.gc
+	c.gc = nil
+	c.lock.Unlock()
+
+	gc.stop()
+	for _, s := range c.allShards() {
+		s.lock.Lock()
+		s.flush()
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// StartAndGC starts GC routine based on config string settings.
+// AdapterConfig: shards=16,max_entries=100000,max_bytes=256MB,policy=lru
+// policy is one of lru, lfu or tinylfu and only applies when a limit is set,
+// tinylfu may drop new keys on Set, see SetExCtx.
+// The limits are split evenly across the shards.
+func (c *MemoryCache) StartAndGC(opt Options) error {
+	cfg, err := ini.Load([]byte(strings.Replace(opt.AdapterConfig, ",", "\n", -1)))
+	if err != nil {
+		return err
+	}
+	codec, err := codecOf(opt, "binary")
+	if err != nil {
+		return err
+	}
+	fields, err := fieldCipherOf(opt)
+	if err != nil {
+		return err
+	}
+
+	var (
+		shards     = defaultMemoryShards
+		maxEntries int
+		maxBytes   int64
+		policy     string
+	)
+	for k, v := range cfg.Section("").KeysHash() {
+		switch k {
+		case "shards":
+			if shards, err = strconv.Atoi(v); err != nil || shards < 1 {
+				return fmt.Errorf("cache/memory: invalid shards '%s'", v)
+			}
+		case "max_entries":
+			if maxEntries, err = strconv.Atoi(v); err != nil || maxEntries < 0 {
+				return fmt.Errorf("cache/memory: invalid max_entries '%s'", v)
+			}
+		case "max_bytes":
+			if maxBytes, err = ParseBytes(v); err != nil {
+				return fmt.Errorf("cache/memory: invalid max_bytes '%s'", v)
+			}
+		case "policy":
+			policy = v
+		default:
+			return fmt.Errorf("cache/memory: unsupported option '%s'", k)
+		}
+	}
+
+	// Round up to a power of two, without giving a shard less than one entry
+	// or byte.
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	for n > 1 && ((maxEntries > 0 && n > maxEntries) || (maxBytes > 0 && int64(n) > maxBytes)) {
+		n >>= 1
+	}
+	next := make([]*memoryShard, n)
+	for i := range next {
+		if next[i], err = newMemoryShard(int(share(int64(maxEntries), n, i)), share(maxBytes, n, i), policy); err != nil {
+			return err
+		}
+	}
+
+	c.lock.Lock()
+	gc := c.gc
+	c.gc = nil
+	c.codec = codec
+	c.fields = fields
+	prev := c.allShards()
+	c.lock.Unlock()
+	gc.stop()
+
+	// Move the entries cached so far into the new shards.
+	var evicted []memoryEntry
+	for _, s := range prev {
+		s.lock.Lock()
+		for key, item := range s.items {
+			t := next[shardIndex(key, uint32(n-1))]
+			e, _ := t.store(key, item)
+			evicted = append(evicted, e...)
+		}
+		s.lock.Unlock()
+	}
+	c.shards.Store(next)
+
+	c.notify(evicted)
+
+	c.lock.Lock()
+	c.closed.Store(false)
+	if opt.Interval > 0 {
+		c.gc = runGC(time.Duration(opt.Interval)*time.Second, c.collect)
+	}
+	c.lock.Unlock()
+	return nil
+}
+
+// share returns the part of limit given to shard i of n, the shares add up
+// to limit exactly.
+func share(limit int64, n, i int) int64 {
+	part := limit / int64(n)
+	if int64(i) < limit%int64(n) {
+		part++
+	}
+	return part
+}
+
+/**
+ * @desc: 存入map数据
+ * @param {string} key
+ * @param {interface{}} data
+ * @return {*}
+ */
+func (c *MemoryCache) HMSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	if key == "" || data == nil {
+		return errors.New("parameter is empty")
+	}
+	fields, err := hashFields(data)
+	if err == nil {
+		fields, err = c.fields.sealAll(fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	s := c.shard(key)
+	s.lock.Lock()
+	evicted, err := s.hset(key, fields)
+	s.lock.Unlock()
+
+	c.notify(evicted)
+	return err
+}
+
+/**
+ * @desc: 解析map数据
+ * @param {map[string]string} val 原数据
+ * @param {interface{}} dst 赋值
+ * @return {*}
+ */
+func (c *MemoryCache) HMScan(val map[string]string, dst interface{}) (err error) {
+	types := reflect.TypeOf(dst)
+	if !(types.Kind() == reflect.Ptr && types.Elem().Kind() == reflect.Struct) {
+		return errors.New("parsing failed")
+	}
+	types = types.Elem()
+	value := reflect.ValueOf(dst).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		scanValue(val, types.Field(i), value.Field(i))
+	}
+	return nil
+}
+
+/**
+ * @desc: 获取多个字段 hash数据
+ * @param key 存入hash的key值
+ * @param field 获取的字段
+ * @return {*}
+ */
+func (c *MemoryCache) HMGetCtx(ctx context.Context, key string, fields []string) (res map[string]string, err error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
+	data, err := c.HGetAllCtx(ctx, key)
+	if err != nil {
+		return res, err
+	}
+	res = make(map[string]string)
+	for _, field := range fields {
+		if v, ok := data[field]; ok {
+			res[field] = v
+		}
+	}
+	return res, nil
+}
+
+/**
+ * @desc: 获取hash数据
+ * @param key 存入hash的key值
+ * @param field 获取的字段
+ * @return {*}
+ */
+func (c *MemoryCache) HGetCtx(ctx context.Context, key, field string) (data string, err error) {
+	if err := c.check(ctx); err != nil {
+		return "", err
+	}
+	s := c.shard(key)
+	unlock := s.lockRead()
+	defer unlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return data, errNotFound(key)
+	}
+	if item.hash == nil {
+		return data, errNotHash(key)
+	}
+	data, ok = item.hash[field]
+	if !ok {
+		return data, errFieldNotFound(key, field)
+	}
+	return c.fields.open(data)
+}
+
+/**
+ * @desc: 存入hash数据
+ * @param {string} key
+ * @param {interface{}} data
+ * @return {*}
+ */
+func (c *MemoryCache) HSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	if data == nil || reflect.TypeOf(data).Kind() != reflect.Map {
+		return errors.New("data must be map")
+	}
+	fields, err := hashFields(data)
+	if err == nil {
+		fields, err = c.fields.sealAll(fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	s := c.shard(key)
+	s.lock.Lock()
+	evicted, err := s.hset(key, fields)
+	s.lock.Unlock()
+
+	c.notify(evicted)
+	return err
+}
+
+/**
+ * @desc: 删除某个key的某个值
+ * @param {*} key
+ * @param {string} field
+ * @return {*}
+ */
+func (c *MemoryCache) HDelCtx(ctx context.Context, key, field string) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, ok := s.get(key)
+	if !ok {
+		return nil
+	}
+	if item.hash == nil {
+		return errNotHash(key)
+	}
+	if _, ok = item.hash[field]; !ok {
+		return nil
+	}
+	if len(item.hash) == 1 {
+		s.remove(key)
+		return nil
+	}
+	s.update(key, item, func() {
+		delete(item.hash, field)
+	})
+	return nil
+}
+
+/**
+ * @desc: 获取所有hash缓存
+ * @param 存入hash的key值
+ * @return {*}
+ */
+func (c *MemoryCache) HGetAllCtx(ctx context.Context, key string) (data map[string]string, err error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
+	s := c.shard(key)
+	unlock := s.lockRead()
+	defer unlock()
+
+	item, ok := s.lookup(key)
+	if !ok {
+		return data, errNotFound(key)
+	}
+	if item.hash == nil {
+		return data, errNotHash(key)
+	}
+	if c.fields != nil {
+		return c.fields.openAll(item.hash)
+	}
+	data = make(map[string]string, len(item.hash))
+	for k, v := range item.hash {
+		data[k] = v
+	}
+	return data, nil
+}
+
+/**
+ * @desc: 设置有效期
+ * @param {string} key
+ * @param {time.Duration} expire
+ * @return {*}
+ */
+func (c *MemoryCache) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, ok := s.get(key)
+	if !ok {
+		return errNotFound(key)
+	}
+	if expire <= 0 {
+		s.remove(key)
+		return nil
+	}
+	item.expire = time.Now().Add(expire).UnixNano()
+	return nil
+}
+
+/**
+ * @desc: 清理
+ * @param {*} bucket
+ * @return {*}
+ */
+func (c *MemoryCache) ClearCtx(ctx context.Context, bucket string) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	for _, s := range c.allShards() {
+		s.lock.Lock()
+		for key := range s.items {
+			if strings.HasPrefix(key, bucket) {
+				s.remove(key)
+			}
+		}
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// SizeCtx returns the approximate number of bytes held by keys under bucket.
+func (c *MemoryCache) SizeCtx(ctx context.Context, bucket string) string {
+	if c.check(ctx) != nil {
+		return "0"
+	}
+	var size uint64
+	now := time.Now().UnixNano()
+	for _, s := range c.allShards() {
+		s.lock.RLock()
+		for key, item := range s.items {
+			if strings.HasPrefix(key, bucket) && !item.hasExpired(now) {
+				size += uint64(item.size(key))
+			}
+		}
+		s.lock.RUnlock()
+	}
+	return fmt.Sprintf("%d", size)
+}
+
+// TTLCtx returns the remaining time to live of key, -1 if it has no expiration
+// and -2 if it does not exist.
+func (c *MemoryCache) TTLCtx(ctx context.Context, key string) time.Duration {
+	if c.check(ctx) != nil {
+		return -2
+	}
+	s := c.shard(key)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	item, ok := s.get(key)
+	if !ok {
+		return -2
+	}
+	if item.expire == 0 {
+		return -1
+	}
+	return time.Duration(item.expire - time.Now().UnixNano())
+}
+
+// TypeCtx returns the type of value stored at key: "string", "hash" or "none".
+func (c *MemoryCache) TypeCtx(ctx context.Context, key string) string {
+	if c.check(ctx) != nil {
+		return "none"
+	}
+	s := c.shard(key)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	item, ok := s.get(key)
+	switch {
+	case !ok:
+		return "none"
+	case item.hash != nil:
+		return "hash"
+	default:
+		return "string"
+	}
+}
+
+// SearchCtx returns the keys matching the glob pattern or prefix bucket.
+func (c *MemoryCache) SearchCtx(ctx context.Context, bucket string) []string {
+	if c.check(ctx) != nil {
+		return []string{}
+	}
+	keys := []string{}
+	now := time.Now().UnixNano()
+	for _, s := range c.allShards() {
+		s.lock.RLock()
+		for key, item := range s.items {
+			if !item.hasExpired(now) && matchKey(bucket, key) {
+				keys = append(keys, key)
+			}
+		}
+		s.lock.RUnlock()
+	}
+	return keys
+}
+
+// ScanCtx lists the keys starting with prefix in lexical order, see Cache.Scan.
+// The keys are collected and sorted when a walk starts, see scanSnapshots.
+func (c *MemoryCache) ScanCtx(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, "", err
+	}
+	keys, _ := c.scans.after(prefix, cursor, func() ([]string, error) {
+		var keys []string
+		now := time.Now().UnixNano()
+		for _, s := range c.allShards() {
+			s.lock.RLock()
+			for key, item := range s.items {
+				if !item.hasExpired(now) && strings.HasPrefix(key, prefix) {
+					keys = append(keys, key)
+				}
+			}
+			s.lock.RUnlock()
+		}
+		return keys, nil
+	})
+	now := time.Now().UnixNano()
+	infos, next := pageKeys(keys, scanLimit(limit), func(key string) (KeyInfo, bool) {
+		s := c.shard(key)
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+		item, ok := s.get(key)
+		if !ok {
+			return KeyInfo{}, false
+		}
+		info := KeyInfo{Key: key, TTL: -1, Type: "string"}
+		if item.expire > 0 {
+			info.TTL = time.Duration(item.expire - now)
+		}
+		if item.hash != nil {
+			info.Type = "hash"
+		}
+		return info, true
+	})
+	return infos, next, nil
+}
+
+// matchKey reports whether key matches the glob pattern or starts with it.
+func matchKey(pattern, key string) bool {
+	if pattern == "" || strings.HasPrefix(key, pattern) {
+		return true
+	}
+	ok, _ := path.Match(pattern, key)
+	return ok
+}
+
+// The Cache methods run their CacheContext counterpart with a background context.
+
+func (c *MemoryCache) Set(key string, val interface{}, expire int64) error {
+	return c.SetCtx(context.Background(), key, val, expire)
+}
+
+func (c *MemoryCache) SetEx(key string, val interface{}, expire time.Duration) error {
+	return c.SetExCtx(context.Background(), key, val, expire)
+}
+
+func (c *MemoryCache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Del(key string) error {
+	return c.DelCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Incr(key string) (err error) {
+	return c.IncrCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Decr(key string) (err error) {
+	return c.DecrCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Exists(key string) bool {
+	return c.ExistsCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Flush() error {
+	return c.FlushCtx(context.Background())
+}
+
+func (c *MemoryCache) HMSet(key string, data interface{}) error {
+	return c.HMSetCtx(context.Background(), key, data)
+}
+
+func (c *MemoryCache) HMGet(key string, fields []string) (res map[string]string, err error) {
+	return c.HMGetCtx(context.Background(), key, fields)
+}
+
+func (c *MemoryCache) HGet(key, field string) (data string, err error) {
+	return c.HGetCtx(context.Background(), key, field)
+}
+
+func (c *MemoryCache) HSet(key string, data interface{}) error {
+	return c.HSetCtx(context.Background(), key, data)
+}
+
+func (c *MemoryCache) HDel(key, field string) (err error) {
+	return c.HDelCtx(context.Background(), key, field)
+}
+
+func (c *MemoryCache) HGetAll(key string) (data map[string]string, err error) {
+	return c.HGetAllCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Expire(key string, expire time.Duration) error {
+	return c.ExpireCtx(context.Background(), key, expire)
+}
+
+func (c *MemoryCache) Clear(bucket string) error {
+	return c.ClearCtx(context.Background(), bucket)
+}
+
+func (c *MemoryCache) Size(bucket string) string {
+	return c.SizeCtx(context.Background(), bucket)
+}
+
+func (c *MemoryCache) TTL(key string) time.Duration {
+	return c.TTLCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Type(key string) string {
+	return c.TypeCtx(context.Background(), key)
+}
+
+func (c *MemoryCache) Search(bucket string) []string {
+	return c.SearchCtx(context.Background(), bucket)
+}
+
+func (c *MemoryCache) Scan(prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	return c.ScanCtx(context.Background(), prefix, cursor, limit)
+}
+
+func init() {
+	RegisterFactory("memory", func(opt Options) (Cache, error) {
+		c := NewMemoryCache()
+		if err := c.StartAndGC(opt); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryItem represents a memory cache item.
+type memoryItem struct {
+	val    interface{}
+	hash   map[string]string // non-nil when the item holds hash fields
+	expire int64             // expiration time in unix nanoseconds, 0 means never
+}
+
+func (item *memoryItem) hasExpired(now int64) bool {
+	return item.expire > 0 && now >= item.expire
+}
+
+// value returns the value or the hash fields of the item.
+func (item *memoryItem) value() interface{} {
+	if item.hash != nil {
+		return item.hash
+	}
+	return item.val
+}
+
+// size returns the approximate memory footprint of the item stored at key.
+func (item *memoryItem) size(key string) int {
+	n := len(key)
+	if item.hash != nil {
+		for k, v := range item.hash {
+			n += len(k) + len(v)
+		}
+		return n
+	}
+	return n + sizeOf(item.val)
+}
+
+// sizeOf returns the size of the item stored at key as accounted against
+// max_bytes, 0 when the shard has no byte limit so values are not walked.
+func (s *memoryShard) sizeOf(key string, item *memoryItem) int64 {
+	if s.maxBytes <= 0 {
+		return 0
+	}
+	return int64(item.size(key))
+}
+
+// memoryEntry is an evicted entry waiting to be reported.
+type memoryEntry struct {
+	key string
+	val interface{}
+}
+
+// memoryShard is one lock stripe of a MemoryCache, it owns a slice of the
+// keyspace together with its share of the limits and its eviction policy.
+type memoryShard struct {
+	lock  sync.RWMutex
+	items map[string]*memoryItem
+
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	policy     evictionPolicy // nil when the cache is unbounded
+	policyName string
+	evictions  uint64
+	rejections uint64
+}
+
+func newMemoryShard(maxEntries int, maxBytes int64, policy string) (*memoryShard, error) {
+	s := &memoryShard{
+		items:      make(map[string]*memoryItem),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		policyName: policy,
+	}
+	if maxEntries > 0 || maxBytes > 0 {
+		p, err := newEvictionPolicy(policy, maxEntries)
+		if err != nil {
+			return nil, err
+		}
+		s.policy = p
+	}
+	return s, nil
+}
+
+// get returns the live item of given key, the caller must hold the lock.
+func (s *memoryShard) get(key string) (*memoryItem, bool) {
+	item, ok := s.items[key]
+	if !ok || item.hasExpired(time.Now().UnixNano()) {
+		return nil, false
+	}
+	return item, true
+}
+
+// lookup is get for callers holding the lock returned by lockRead,
+// it also records the access for the eviction policy.
+func (s *memoryShard) lookup(key string) (*memoryItem, bool) {
+	if s.policy != nil {
+		s.policy.access(key)
+	}
+	return s.get(key)
+}
+
+// lockRead locks the shard for a lookup. Bounded shards take the write lock
+// since every lookup updates the eviction policy.
+func (s *memoryShard) lockRead() (unlock func()) {
+	if s.policy == nil {
+		s.lock.RLock()
+		return s.lock.RUnlock
+	}
+	s.lock.Lock()
+	return s.lock.Unlock
+}
+
+// store inserts or replaces the item of key and evicts entries until the
+// shard fits its limits again, the caller must hold the lock.
+func (s *memoryShard) store(key string, item *memoryItem) ([]memoryEntry, error) {
+	size := s.sizeOf(key, item)
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return nil, fmt.Errorf("cache/memory: value of '%s' exceeds max_bytes", key)
+	}
+
+	if old, ok := s.items[key]; ok {
+		s.bytes += size - s.sizeOf(key, old)
+		s.items[key] = item
+		if s.policy != nil {
+			s.policy.access(key)
+		}
+		return s.shrink(key), nil
+	}
+
+	var evicted []memoryEntry
+	if s.policy != nil {
+		s.policy.access(key)
+		for s.full(1, size) {
+			victim, ok := s.policy.victim()
+			if !ok {
+				break
+			}
+			if !s.policy.admit(key, victim) {
+				// The new entry is dropped instead, it is reported like an eviction.
+				s.rejections++
+				return append(evicted, memoryEntry{key: key, val: item.value()}), nil
+			}
+			evicted = append(evicted, s.evict(victim))
+		}
+	}
+	s.items[key] = item
+	s.bytes += size
+	if s.policy != nil {
+		s.policy.add(key)
+	}
+	return evicted, nil
+}
+
+// hset merges fields into the hash stored at key, the caller must hold the lock.
+func (s *memoryShard) hset(key string, fields map[string]string) ([]memoryEntry, error) {
+	item, ok := s.lookup(key)
+	if !ok {
+		return s.store(key, &memoryItem{hash: fields})
+	}
+	if item.hash == nil {
+		return nil, errNotHash(key)
+	}
+	hash := make(map[string]string, len(item.hash)+len(fields))
+	for k, v := range item.hash {
+		hash[k] = v
+	}
+	for k, v := range fields {
+		hash[k] = v
+	}
+	return s.store(key, &memoryItem{hash: hash, expire: item.expire})
+}
+
+// update applies fn changing the item of key in place and accounts for its
+// new size, the caller must hold the lock.
+func (s *memoryShard) update(key string, item *memoryItem, fn func()) {
+	size := s.sizeOf(key, item)
+	fn()
+	s.bytes += s.sizeOf(key, item) - size
+}
+
+// full reports whether adding entries of size bytes would exceed the limits.
+func (s *memoryShard) full(entries int, size int64) bool {
+	return (s.maxEntries > 0 && len(s.items)+entries > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes+size > s.maxBytes)
+}
+
+// shrink evicts entries other than keep while the shard exceeds its limits.
+func (s *memoryShard) shrink(keep string) (evicted []memoryEntry) {
+	if s.policy == nil {
+		return nil
+	}
+	for s.full(0, 0) {
+		victim, ok := s.policy.victim()
+		if !ok {
+			break
+		}
+		if victim == keep {
+			// The kept key is the last resort, refresh it and try the next one.
+			s.policy.access(keep)
+			if next, _ := s.policy.victim(); next == keep {
+				break
+			}
+			continue
+		}
+		evicted = append(evicted, s.evict(victim))
+	}
+	return evicted
+}
+
+// evict removes key to make room and counts it as an eviction.
+func (s *memoryShard) evict(key string) memoryEntry {
+	entry := memoryEntry{key: key}
+	if item, ok := s.items[key]; ok {
+		entry.val = item.value()
+	}
+	s.remove(key)
+	s.evictions++
+	return entry
+}
+
+// remove deletes key and its accounting, the caller must hold the lock.
+func (s *memoryShard) remove(key string) {
+	item, ok := s.items[key]
+	if !ok {
+		return
+	}
+	s.bytes -= s.sizeOf(key, item)
+	delete(s.items, key)
+	if s.policy != nil {
+		s.policy.remove(key)
+	}
+}
+
+// flush drops every item, the caller must hold the lock.
+func (s *memoryShard) flush() {
+	s.items = make(map[string]*memoryItem)
+	s.bytes = 0
+	if s.policy != nil {
+		s.policy, _ = newEvictionPolicy(s.policyName, s.maxEntries)
+	}
+}
+
+// gc drops expired items.
+func (s *memoryShard) gc(now int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, item := range s.items {
+		if item.hasExpired(now) {
+			s.remove(key)
+		}
+	}
+}
+
+// shardIndex hashes key with FNV-1a without allocating.
+func shardIndex(key string, mask uint32) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h & mask
+}
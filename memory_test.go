@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type node struct {
+	Name   string
+	Parent *node
+	Kids   []*node
+}
+
+func TestMemorySetCyclicValue(t *testing.T) {
+	root := &node{Name: "root"}
+	root.Kids = append(root.Kids, &node{Name: "kid", Parent: root})
+
+	for _, config := range []string{"", "max_bytes=1MB"} {
+		c := NewMemoryCache()
+		if err := c.StartAndGC(Options{AdapterConfig: config}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Set("tree", root, 0); err != nil {
+			t.Fatalf("%q: Set: %v", config, err)
+		}
+		if !c.Exists("tree") {
+			t.Fatalf("%q: tree not stored", config)
+		}
+		if size := c.Size(""); size == "0" {
+			t.Fatalf("%q: Size is 0", config)
+		}
+		c.Close()
+	}
+}
+
+func TestMemoryTinyLFURejectionReported(t *testing.T) {
+	c := NewMemoryCache()
+	if err := c.StartAndGC(Options{AdapterConfig: "shards=1,max_entries=2,policy=tinylfu"}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var reported []string
+	c.OnEvict(func(key string, _ interface{}) {
+		reported = append(reported, key)
+	})
+
+	// Make the resident keys frequent so a cold key is refused.
+	for i := 0; i < 2; i++ {
+		c.Set(fmt.Sprintf("hot%d", i), i, 0)
+		for j := 0; j < 10; j++ {
+			c.Get(fmt.Sprintf("hot%d", i))
+		}
+	}
+	if err := c.Set("cold", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.Exists("cold") {
+		t.Fatal("cold key admitted over frequent ones")
+	}
+	if c.Stats().Rejections != 1 {
+		t.Fatalf("Rejections = %d, want 1", c.Stats().Rejections)
+	}
+	if len(reported) != 1 || reported[0] != "cold" {
+		t.Fatalf("OnEvict got %v, want [cold]", reported)
+	}
+}
+
+func TestMemoryLimitsBound(t *testing.T) {
+	for _, max := range []int{1, 10, 100, 1000} {
+		for _, policy := range []string{"lru", "lfu", "tinylfu"} {
+			config := fmt.Sprintf("max_entries=%d,max_bytes=%d,policy=%s", max, max*32, policy)
+			c := startTestCache(t, NewMemoryCache(), Options{AdapterConfig: config})
+			for i := 0; i < 5*max; i++ {
+				c.Set(strconv.Itoa(i), i, 0)
+			}
+			if stats := c.(*MemoryCache).Stats(); stats.Entries > max || stats.Bytes > int64(max*32) {
+				t.Errorf("%s: Stats = %+v", config, stats)
+			}
+		}
+	}
+}
+
+func TestMemoryBytesAccounting(t *testing.T) {
+	for _, config := range []string{"", "max_bytes=1MB"} {
+		// c gets there by HDel and Incr, want by setting the result directly.
+		c := startTestCache(t, NewMemoryCache(), Options{AdapterConfig: config, Codec: "json"})
+		want := startTestCache(t, NewMemoryCache(), Options{AdapterConfig: config, Codec: "json"})
+
+		c.HSet("hash", map[string]string{"a": "1", "long": "some longer value"})
+		if err := c.HDel("hash", "long"); err != nil {
+			t.Fatal(err)
+		}
+		want.HSet("hash", map[string]string{"a": "1"})
+		c.Set("counter", 9, 0)
+		if err := c.Incr("counter"); err != nil {
+			t.Fatal(err)
+		}
+		want.Set("counter", 10, 0)
+
+		got, exp := c.(*MemoryCache).Stats().Bytes, want.(*MemoryCache).Stats().Bytes
+		if got != exp || got < 0 {
+			t.Errorf("%q: Bytes = %d, want %d", config, got, exp)
+		}
+		c.Del("hash")
+		c.Del("counter")
+		if got := c.(*MemoryCache).Stats().Bytes; got != 0 {
+			t.Errorf("%q: Bytes = %d once empty, want 0", config, got)
+		}
+	}
+}
+
+const benchKeys = 1 << 14
+
+// getSetter is the part of a cache the benchmarks exercise.
+type getSetter interface {
+	Set(key string, val interface{}, expire int64) error
+	Get(key string) (interface{}, error)
+}
+
+// lockedMap is the single-lock baseline: a map guarded by one RWMutex.
+type lockedMap struct {
+	lock  sync.RWMutex
+	items map[string]interface{}
+}
+
+func (m *lockedMap) Set(key string, val interface{}, _ int64) error {
+	m.lock.Lock()
+	m.items[key] = val
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *lockedMap) Get(key string) (interface{}, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	val, ok := m.items[key]
+	if !ok {
+		return nil, errNotFound(key)
+	}
+	return val, nil
+}
+
+func fill(b *testing.B, c getSetter) getSetter {
+	for i := 0; i < benchKeys; i++ {
+		if err := c.Set(strconv.Itoa(i), i, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return c
+}
+
+func newBenchMemory(b *testing.B, config string) getSetter {
+	c := NewMemoryCache()
+	if err := c.StartAndGC(Options{AdapterConfig: config}); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { c.Close() })
+	return fill(b, c)
+}
+
+// benchMixed runs Get and Set from many goroutines, writes is the share of
+// Set calls in percent.
+func benchMixed(b *testing.B, writes int) {
+	caches := []struct {
+		name string
+		new  func(b *testing.B) getSetter
+	}{
+		{"single-lock", func(b *testing.B) getSetter {
+			return fill(b, &lockedMap{items: make(map[string]interface{})})
+		}},
+		{"sharded-16", func(b *testing.B) getSetter { return newBenchMemory(b, "shards=16") }},
+		{"sharded-64", func(b *testing.B) getSetter { return newBenchMemory(b, "shards=64") }},
+		{"sharded-64-lru", func(b *testing.B) getSetter {
+			return newBenchMemory(b, "shards=64,max_entries=100000,policy=lru")
+		}},
+	}
+	for _, bc := range caches {
+		b.Run(bc.name, func(b *testing.B) {
+			c := bc.new(b)
+			b.SetParallelism(64)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					key := strconv.Itoa(r.Intn(benchKeys))
+					if r.Intn(100) < writes {
+						c.Set(key, key, 0)
+					} else {
+						c.Get(key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemoryWrites10(b *testing.B) {
+	benchMixed(b, 10)
+}
+
+func BenchmarkMemoryWrites50(b *testing.B) {
+	benchMixed(b, 50)
+}
@@ -2,12 +2,16 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 
 	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -21,75 +25,150 @@ type RedisCache struct {
 	prefix     string
 	hsetName   string
 	occupyMode bool
+	closed     atomic.Bool
+	codec      Codec        // encodes the values when set, otherwise they are stored as JSON
+	fields     *fieldCipher // encrypts the values of hash fields when set
+	channel    string       // invalidation channel, changes are not published when empty
+	id         string       // identifies the messages this instance publishes
+	scanCount  int64        // keys asked per SCAN call and deleted per UNLINK call
 }
 
-var ctx = context.Background()
+// defaultScanCount is the number of keys Clear and Search handle per round trip.
+const defaultScanCount = 1000
 
-// Set puts value into cache with key and expire time.
+// NewRedisCache creates and returns a new redis cacher.
+func NewRedisCache() *RedisCache {
+	return &RedisCache{}
+}
+
+// SetCtx puts value into cache with key and expire time in seconds.
 // If expired is 0, it lives forever.
-func (c *RedisCache) Set(key string, val interface{}, expire int64) error {
-	if isNotNumber(val) {
-		val, _ = json.Marshal(val)
+func (c *RedisCache) SetCtx(ctx context.Context, key string, val interface{}, expire int64) error {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	key = c.prefix + key
-	if expire == 0 {
-		if err := c.client.Set(ctx, key, ToStr(val), 0).Err(); err != nil {
-			return err
-		}
-	} else {
-		dur, err := time.ParseDuration(ToStr(expire) + "s")
+	return c.SetExCtx(ctx, key, val, time.Duration(expire)*time.Second)
+}
+
+// SetExCtx puts value into cache with key and expire duration.
+func (c *RedisCache) SetExCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	if c.codec != nil {
+		data, err := c.codec.Marshal(val)
 		if err != nil {
 			return err
 		}
-		if err = c.client.SetEx(ctx, key, ToStr(val), dur).Err(); err != nil {
+		val = data
+	} else if isNotNumber(val) {
+		val, _ = json.Marshal(val)
+	}
+	k := c.prefix + key
+	if err := c.client.Set(ctx, k, ToStr(val), redisExpiry(expire)).Err(); err != nil {
+		return redisError(key, err)
+	}
+	if !c.occupyMode {
+		if err := c.client.HSet(ctx, c.hsetName, k, "0").Err(); err != nil {
 			return err
 		}
 	}
-	if c.occupyMode {
-		return nil
-	}
-	return c.client.HSet(ctx, c.hsetName, key, "0").Err()
+	return c.publish(ctx, InvalidateKey, key)
 }
 
-// Get gets cached value by given key.
-func (c *RedisCache) Get(key string) (interface{}, error) {
+// GetCtx gets cached value by given key.
+func (c *RedisCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
 	val, err := c.client.Get(ctx, c.prefix+key).Result()
 	if err != nil {
-		return nil, err
+		return nil, redisError(key, err)
+	}
+	if c.codec != nil {
+		return decodeWith(c.codec, []byte(val))
 	}
 	return val, nil
 }
 
-// Delete deletes cached value by given key.
-func (c *RedisCache) Del(key string) error {
-	key = c.prefix + key
-	if err := c.client.Del(ctx, key).Err(); err != nil {
+// DelCtx deletes cached value by given key.
+func (c *RedisCache) DelCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
 		return err
 	}
-	if c.occupyMode {
-		return nil
+	k := c.prefix + key
+	if err := c.client.Del(ctx, k).Err(); err != nil {
+		return redisError(key, err)
+	}
+	if !c.occupyMode {
+		if err := c.client.HDel(ctx, c.hsetName, k).Err(); err != nil {
+			return err
+		}
 	}
-	return c.client.HDel(ctx, c.hsetName, key).Err()
+	return c.publish(ctx, InvalidateKey, key)
 }
 
-// Incr increases cached int-type value by given key as a counter.
-func (c *RedisCache) Incr(key string) error {
-	if !c.Exists(key) {
-		return fmt.Errorf("key '%s' not exist", key)
+// IncrCtx increases cached int-type value by given key as a counter.
+func (c *RedisCache) IncrCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	return c.client.Incr(ctx, c.prefix+key).Err()
+	if c.codec != nil {
+		return c.changed(ctx, key, c.counter(ctx, key, Incr))
+	}
+	if !c.ExistsCtx(ctx, key) {
+		return errNotFound(key)
+	}
+	return c.changed(ctx, key, redisError(key, c.client.Incr(ctx, c.prefix+key).Err()))
 }
 
-// Decr decreases cached int-type value by given key as a counter.
-func (c *RedisCache) Decr(key string) error {
-	if !c.Exists(key) {
-		return fmt.Errorf("key '%s' not exist", key)
+// DecrCtx decreases cached int-type value by given key as a counter.
+func (c *RedisCache) DecrCtx(ctx context.Context, key string) error {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	return c.client.Decr(ctx, c.prefix+key).Err()
+	if c.codec != nil {
+		return c.changed(ctx, key, c.counter(ctx, key, Decr))
+	}
+	if !c.ExistsCtx(ctx, key) {
+		return errNotFound(key)
+	}
+	return c.changed(ctx, key, redisError(key, c.client.Decr(ctx, c.prefix+key).Err()))
 }
 
-// IsExist returns true if cached value exists.
-func (c *RedisCache) Exists(key string) bool {
+// counter applies fn, Incr or Decr, to a value encoded by the codec, which
+// INCR and DECR cannot read. The key is watched and the update retried when
+// another client changes it in between, its expiration is kept.
+func (c *RedisCache) counter(ctx context.Context, key string, fn func(interface{}) (interface{}, error)) error {
+	k := c.prefix + key
+	for {
+		err := c.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, k).Bytes()
+			if err != nil {
+				return redisError(key, err)
+			}
+			if data, err = encodeCounter(c.codec, data, fn); err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return pipe.SetArgs(ctx, k, data, redis.SetArgs{KeepTTL: true}).Err()
+			})
+			return err
+		}, k)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// ExistsCtx returns true if cached value exists.
+func (c *RedisCache) ExistsCtx(ctx context.Context, key string) bool {
+	if c.check(ctx) != nil {
+		return false
+	}
 	state, err := c.client.Exists(ctx, c.prefix+key).Result()
 	if state > 0 && err == nil {
 		return true
@@ -100,8 +179,11 @@ func (c *RedisCache) Exists(key string) bool {
 	return false
 }
 
-// Flush deletes all cached data.
-func (c *RedisCache) Flush() error {
+// FlushCtx deletes all cached data.
+func (c *RedisCache) FlushCtx(ctx context.Context) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
 
 	keys, err := c.client.HKeys(ctx, c.hsetName).Result()
 	if err != nil {
@@ -110,15 +192,29 @@ func (c *RedisCache) Flush() error {
 	if err = c.client.Del(ctx, keys...).Err(); err != nil {
 		return err
 	}
-	return c.client.Del(ctx, c.hsetName).Err()
+	if err = c.client.Del(ctx, c.hsetName).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, InvalidateFlush, "")
 }
 
 // StartAndGC starts GC routine based on config string settings.
-// AdapterConfig: network=tcp,addr=:6379,password=123456,db=0,pool_size=100,idle_timeout=180,hset_name=Cache,prefix=cache:
+// AdapterConfig: network=tcp,addr=:6379,password=123456,db=0,pool_size=100,idle_timeout=180,hset_name=Cache,prefix=cache:,invalidation_channel=cache:invalidate,scan_count=1000
+// idle_timeout is in seconds, -1 keeps idle connections open.
 func (c *RedisCache) StartAndGC(opts Options) error {
 
 	c.hsetName = "Cache"
+	c.channel = ""
+	c.scanCount = defaultScanCount
 	c.occupyMode = opts.OccupyMode
+	codec, err := codecOf(opts, "json")
+	if err != nil {
+		return err
+	}
+	c.codec = codec
+	if c.fields, err = fieldCipherOf(opts); err != nil {
+		return err
+	}
 
 	cfg, err := ini.Load([]byte(strings.Replace(opts.AdapterConfig, ",", "\n", -1)))
 	if err != nil {
@@ -137,34 +233,159 @@ func (c *RedisCache) StartAndGC(opts Options) error {
 		case "password":
 			opt.Password = v
 		case "db":
-			opt.DB = 0
+			num, err := strconv.Atoi(v)
+			if err != nil || num < 0 {
+				return fmt.Errorf("cache/redis: invalid db '%s'", v)
+			}
+			opt.DB = num
 		case "pool_size":
-			num, _ := strconv.Atoi(v)
+			num, err := strconv.Atoi(v)
+			if err != nil || num <= 0 {
+				return fmt.Errorf("cache/redis: invalid pool_size '%s'", v)
+			}
 			opt.PoolSize = num
+		case "idle_timeout":
+			num, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("cache/redis: invalid idle_timeout '%s'", v)
+			}
+			// In seconds, -1 keeps idle connections open.
+			opt.ConnMaxIdleTime = time.Duration(num) * time.Second
+			if num < 0 {
+				opt.ConnMaxIdleTime = -1
+			}
 		case "hset_name":
 			c.hsetName = v
 		case "prefix":
 			c.prefix = v
+		case "invalidation_channel":
+			c.channel = v
+		case "scan_count":
+			num, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || num <= 0 {
+				return fmt.Errorf("cache/redis: invalid scan_count '%s'", v)
+			}
+			c.scanCount = num
 		default:
-			return fmt.Errorf("session/redis: unsupported option '%s'", k)
+			return fmt.Errorf("cache/redis: unsupported option '%s'", k)
 		}
 	}
 
+	if c.client != nil {
+		c.client.Close()
+	}
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+	c.id = hex.EncodeToString(id)
 	c.client = redis.NewClient(opt)
-	if err = c.client.Ping(ctx).Err(); err != nil {
+	c.closed.Store(false)
+	if err = c.client.Ping(context.Background()).Err(); err != nil {
+		c.closed.Store(true)
+		c.client.Close()
 		return err
 	}
 
 	return nil
 }
 
+// check returns the error of ctx, or ErrClosed when the client is closed
+// or was never started.
+func (c *RedisCache) check(ctx context.Context) error {
+	if c.client == nil || c.closed.Load() {
+		return ErrClosed
+	}
+	return ctx.Err()
+}
+
+// Close closes the redis client.
+func (c *RedisCache) Close() error {
+	if c.client == nil || c.closed.Swap(true) {
+		return ErrClosed
+	}
+	return c.client.Close()
+}
+
+// Operations of an Invalidation.
+const (
+	InvalidateKey    = "del"   // Key was changed or deleted
+	InvalidateBucket = "clear" // the keys starting with Key were deleted
+	InvalidateFlush  = "flush" // every key was deleted
+)
+
+// Invalidation tells the other instances sharing a redis that cached copies
+// of some keys are out of date.
+type Invalidation struct {
+	Op  string `json:"op"`
+	Key string `json:"key,omitempty"`
+}
+
+// invalidationMessage is an Invalidation as published on the channel.
+type invalidationMessage struct {
+	ID string `json:"id"` // the publishing instance
+	Invalidation
+}
+
+// publish announces a change on the invalidation channel, if one is set.
+func (c *RedisCache) publish(ctx context.Context, op, key string) error {
+	if len(c.channel) == 0 {
+		return nil
+	}
+	msg, err := json.Marshal(invalidationMessage{ID: c.id, Invalidation: Invalidation{Op: op, Key: key}})
+	if err != nil {
+		return err
+	}
+	return c.client.Publish(ctx, c.channel, msg).Err()
+}
+
+// changed publishes a change of key unless err tells it failed.
+func (c *RedisCache) changed(ctx context.Context, key string, err error) error {
+	if err != nil {
+		return err
+	}
+	return c.publish(ctx, InvalidateKey, key)
+}
+
+// Subscribe calls fn for every invalidation published by the other instances
+// on the invalidation channel, until unsubscribe is called. It returns a nil
+// unsubscribe when no channel is set. Messages published while the
+// connection is down are lost.
+func (c *RedisCache) Subscribe(fn func(Invalidation)) (unsubscribe func() error, err error) {
+	if err := c.check(context.Background()); err != nil {
+		return nil, err
+	}
+	if len(c.channel) == 0 {
+		return nil, nil
+	}
+	ps := c.client.Subscribe(context.Background(), c.channel)
+	if _, err := ps.Receive(context.Background()); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	id := c.id
+	go func() {
+		for m := range ps.Channel() {
+			var msg invalidationMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil || msg.ID == id {
+				continue
+			}
+			fn(msg.Invalidation)
+		}
+	}()
+	return ps.Close, nil
+}
+
 /**
  * @desc: 存入map数据
  * @param {string} key
  * @param {interface{}} data
  * @return {*}
  */
-func (c *RedisCache) HMSet(key string, data interface{}) error {
+func (c *RedisCache) HMSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
 	if data == nil {
 		return errors.New("parameter is empty")
 	}
@@ -188,10 +409,19 @@ func (c *RedisCache) HMSet(key string, data interface{}) error {
 			}
 		}
 	}
+	if c.fields != nil {
+		for k, v := range values {
+			sealed, err := c.fields.seal(ToStr(v))
+			if err != nil {
+				return err
+			}
+			values[k] = sealed
+		}
+	}
 	if err := c.client.HMSet(ctx, c.prefix+key, values).Err(); err != nil {
-		return err
+		return redisError(key, err)
 	}
-	return nil
+	return c.publish(ctx, InvalidateKey, key)
 }
 
 /**
@@ -223,17 +453,25 @@ func (c *RedisCache) HMScan(val map[string]string, dst interface{}) (err error)
  * @param field 获取的字段
  * @return {*}
  */
-func (c *RedisCache) HMGet(key string, fields []string) (res map[string]string, err error) {
+func (c *RedisCache) HMGetCtx(ctx context.Context, key string, fields []string) (res map[string]string, err error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
 	data, err := c.client.HMGet(ctx, c.prefix+key, fields...).Result()
 	if err != nil {
-		return res, errors.New("Get failed")
+		return res, redisError(key, err)
 	}
 	newData := make(map[string]string)
 	for i, v := range data {
 		if v != nil {
-			newData[fields[i]] = ToStr(v)
+			if newData[fields[i]], err = c.fields.open(ToStr(v)); err != nil {
+				return nil, err
+			}
 		}
 	}
+	if len(newData) == 0 && !c.ExistsCtx(ctx, key) {
+		return newData, errNotFound(key)
+	}
 	return newData, nil
 }
 
@@ -243,18 +481,21 @@ func (c *RedisCache) HMGet(key string, fields []string) (res map[string]string,
  * @param field 获取的字段
  * @return {*}
  */
-func (c *RedisCache) HGet(key, field string) (data string, err error) {
-	if c.client == nil {
-		return data, errors.New("redis Error")
+func (c *RedisCache) HGetCtx(ctx context.Context, key, field string) (data string, err error) {
+	if err := c.check(ctx); err != nil {
+		return "", err
 	}
 	if key == "" {
 		return data, errors.New("parameter is empty")
 	}
 	data, err = c.client.HGet(ctx, c.prefix+key, field).Result()
+	if errors.Is(err, redis.Nil) {
+		return data, errFieldNotFound(key, field)
+	}
 	if err != nil {
-		return data, errors.New("Get failed")
+		return data, redisError(key, err)
 	}
-	return data, nil
+	return c.fields.open(data)
 }
 
 /**
@@ -263,12 +504,21 @@ func (c *RedisCache) HGet(key, field string) (data string, err error) {
  * @param {interface{}} data
  * @return {*}
  */
-func (c *RedisCache) HSet(key string, data interface{}) error {
-	err := c.client.HSet(ctx, c.prefix+key, data).Err()
-	if err != nil {
-		return errors.New("add failed")
+func (c *RedisCache) HSetCtx(ctx context.Context, key string, data interface{}) error {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	return nil
+	if c.fields != nil {
+		fields, err := hashFields(data)
+		if err == nil {
+			data, err = c.fields.sealAll(fields)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err := c.client.HSet(ctx, c.prefix+key, data).Err()
+	return c.changed(ctx, key, redisError(key, err))
 }
 
 /**
@@ -277,9 +527,12 @@ func (c *RedisCache) HSet(key string, data interface{}) error {
  * @param {string} field
  * @return {*}
  */
-func (c *RedisCache) HDel(key, field string) (err error) {
+func (c *RedisCache) HDelCtx(ctx context.Context, key, field string) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
 	err = c.client.HDel(ctx, c.prefix+key, field).Err()
-	return err
+	return c.changed(ctx, key, redisError(key, err))
 }
 
 /**
@@ -287,15 +540,21 @@ func (c *RedisCache) HDel(key, field string) (err error) {
  * @param 存入hash的key值
  * @return {*}
  */
-func (c *RedisCache) HGetAll(key string) (data map[string]string, err error) {
+func (c *RedisCache) HGetAllCtx(ctx context.Context, key string) (data map[string]string, err error) {
+	if err := c.check(ctx); err != nil {
+		return nil, err
+	}
 	if key == "" {
 		return data, errors.New("parameter is empty")
 	}
 	data, err = c.client.HGetAll(ctx, c.prefix+key).Result()
 	if err != nil {
-		return data, err
+		return data, redisError(key, err)
 	}
-	return data, nil
+	if len(data) == 0 {
+		return data, errNotFound(key)
+	}
+	return c.fields.openAll(data)
 }
 
 /**
@@ -304,12 +563,21 @@ func (c *RedisCache) HGetAll(key string) (data map[string]string, err error) {
  * @param {time.Duration} expire
  * @return {*}
  */
-func (c *RedisCache) Expire(key string, expire time.Duration) error {
-	state, err := c.client.Expire(ctx, c.prefix+key, expire).Result()
-	if state || err == nil {
-		return nil
+func (c *RedisCache) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
 	}
-	return err
+	if expire <= 0 {
+		return c.DelCtx(ctx, key)
+	}
+	state, err := c.client.PExpire(ctx, c.prefix+key, redisExpiry(expire)).Result()
+	if err != nil {
+		return redisError(key, err)
+	}
+	if !state {
+		return errNotFound(key)
+	}
+	return c.publish(ctx, InvalidateKey, key)
 }
 
 /**
@@ -317,17 +585,48 @@ func (c *RedisCache) Expire(key string, expire time.Duration) error {
  * @param {*} bucket
  * @return {*}
  */
-func (c *RedisCache) Clear(key string) (err error) {
-	keys, err := c.client.Keys(ctx, c.prefix+key+"*").Result()
-	if err == nil {
-		if len(keys) > 0 {
-			c.client.Del(ctx, keys...)
+func (c *RedisCache) ClearCtx(ctx context.Context, key string) (err error) {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	it := c.Keys(ctx, escapeGlob(c.prefix+key)+"*")
+	batch := make([]string, 0, c.scanCount)
+	for it.Next(ctx) {
+		batch = append(batch, it.Key())
+		if len(batch) == cap(batch) {
+			if err := c.unlink(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
 		}
 	}
-	return nil
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if err := c.unlink(ctx, batch); err != nil {
+		return err
+	}
+	return c.publish(ctx, InvalidateBucket, key)
 }
 
-func (c *RedisCache) Size(bucket string) string {
+// unlink deletes keys without blocking the server on large values.
+func (c *RedisCache) unlink(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := c.client.Unlink(ctx, keys...).Err(); err != nil {
+		return err
+	}
+	if c.occupyMode {
+		return nil
+	}
+	return c.client.HDel(ctx, c.hsetName, keys...).Err()
+}
+
+func (c *RedisCache) SizeCtx(ctx context.Context, bucket string) string {
+	if c.check(ctx) != nil {
+		return "0"
+	}
 	info, err := c.client.Info(ctx, "memory").Result()
 	if err != nil {
 		return "0"
@@ -336,15 +635,30 @@ func (c *RedisCache) Size(bucket string) string {
 	return usedMemory
 }
 
-func (c *RedisCache) TTL(key string) time.Duration {
-	res, err := c.client.TTL(ctx, c.prefix+key).Result()
+func (c *RedisCache) TTLCtx(ctx context.Context, key string) time.Duration {
+	if c.check(ctx) != nil {
+		return -2
+	}
+	res, err := c.client.PTTL(ctx, c.prefix+key).Result()
 	if err != nil {
 		return res
 	}
 	return res
 }
 
-func (c *RedisCache) Type(key string) string {
+// redisExpiry rounds expire up to the millisecond redis expires keys by, so
+// values never expire early, 0 for no expiration.
+func redisExpiry(expire time.Duration) time.Duration {
+	if expire <= 0 {
+		return 0
+	}
+	return (expire + time.Millisecond - 1).Truncate(time.Millisecond)
+}
+
+func (c *RedisCache) TypeCtx(ctx context.Context, key string) string {
+	if c.check(ctx) != nil {
+		return "none"
+	}
 	res, err := c.client.Type(ctx, c.prefix+key).Result()
 	if err != nil {
 		return res
@@ -352,15 +666,164 @@ func (c *RedisCache) Type(key string) string {
 	return res
 }
 
-func (c *RedisCache) Search(bucket string) []string {
-	// 获取所有键
-	keys, err := c.client.Keys(ctx, bucket).Result()
-	if err != nil {
+func (c *RedisCache) SearchCtx(ctx context.Context, bucket string) []string {
+	if c.check(ctx) != nil {
+		return []string{}
+	}
+	keys := []string{}
+	it := c.Keys(ctx, bucket)
+	for it.Next(ctx) {
+		keys = append(keys, it.Key())
+	}
+	if it.Err() != nil {
 		return []string{}
 	}
 	return keys
 }
 
+// ScanCtx lists the keys starting with prefix with SCAN, see Cache.Scan.
+// SCAN is called until it returned limit keys, its COUNT being only a hint,
+// the keys it returned beyond limit are kept in the cursor for the next
+// page. Keys may be listed twice.
+func (c *RedisCache) ScanCtx(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, "", err
+	}
+	pos, names, err := parseRedisCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit = scanLimit(limit)
+	// A cursor at 0 with pending keys is one of the last page of SCAN.
+	scanning := pos != 0 || len(names) == 0
+	for scanning && len(names) < limit {
+		var batch []string
+		batch, pos, err = c.client.Scan(ctx, pos, escapeGlob(c.prefix+prefix)+"*", int64(limit-len(names))).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		names = append(names, batch...)
+		scanning = pos != 0
+	}
+	var pending []string
+	if len(names) > limit {
+		names, pending = names[:limit], names[limit:]
+	}
+	next := ""
+	if scanning || len(pending) > 0 {
+		next = formatRedisCursor(pos, pending)
+	}
+
+	ttls := make([]*redis.DurationCmd, len(names))
+	types := make([]*redis.StatusCmd, len(names))
+	if _, err = c.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, name := range names {
+			ttls[i] = p.PTTL(ctx, name)
+			types[i] = p.Type(ctx, name)
+		}
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
+	keys := make([]KeyInfo, 0, len(names))
+	for i, name := range names {
+		ttl, typ := ttls[i].Val(), types[i].Val()
+		// Skip the keys expired since SCAN and the hash tracking the keys.
+		if ttl == -2 || typ == "none" || (!c.occupyMode && name == c.hsetName) {
+			continue
+		}
+		keys = append(keys, KeyInfo{Key: strings.TrimPrefix(name, c.prefix), TTL: ttl, Type: typ})
+	}
+	return keys, next, nil
+}
+
+// formatRedisCursor returns the Scan cursor resuming SCAN at pos after
+// listing the pending keys, the SCAN cursor alone when there are none.
+func formatRedisCursor(pos uint64, pending []string) string {
+	next := strconv.FormatUint(pos, 10)
+	if len(pending) == 0 {
+		return next
+	}
+	data, _ := json.Marshal(pending)
+	return next + ":" + base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseRedisCursor parses a cursor of formatRedisCursor, "" starts a scan.
+func parseRedisCursor(cursor string) (pos uint64, pending []string, err error) {
+	if len(cursor) == 0 {
+		return 0, nil, nil
+	}
+	num, rest, ok := strings.Cut(cursor, ":")
+	if pos, err = strconv.ParseUint(num, 10, 64); err == nil && ok {
+		var data []byte
+		if data, err = base64.RawURLEncoding.DecodeString(rest); err == nil {
+			err = json.Unmarshal(data, &pending)
+		}
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("cache/redis: invalid cursor '%s'", cursor)
+	}
+	return pos, pending, nil
+}
+
+// escapeGlob escapes the characters of s that SCAN MATCH patterns interpret.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// KeyIterator walks the keys matching a pattern with SCAN, fetching them a
+// batch at a time, so large keyspaces neither block the server nor have to
+// fit in memory. Keys may be returned more than once and keys added during
+// the walk may be missed, as with SCAN.
+type KeyIterator struct {
+	it  *redis.ScanIterator
+	err error
+}
+
+// Keys returns an iterator over the keys matching the glob pattern, with the
+// prefix included:
+//
+//	it := c.Keys(ctx, "cache:user:*")
+//	for it.Next(ctx) {
+//		fmt.Println(it.Key())
+//	}
+//	err := it.Err()
+func (c *RedisCache) Keys(ctx context.Context, pattern string) *KeyIterator {
+	if err := c.check(ctx); err != nil {
+		return &KeyIterator{err: err}
+	}
+	return &KeyIterator{it: c.client.Scan(ctx, 0, pattern, c.scanCount).Iterator()}
+}
+
+// Next advances to the next key, it returns false when the keys are exhausted
+// or an error occurred.
+func (it *KeyIterator) Next(ctx context.Context) bool {
+	return it.it != nil && it.it.Next(ctx)
+}
+
+// Key returns the current key.
+func (it *KeyIterator) Key() string {
+	if it.it == nil {
+		return ""
+	}
+	return it.it.Val()
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *KeyIterator) Err() error {
+	if it.err != nil || it.it == nil {
+		return it.err
+	}
+	return it.it.Err()
+}
+
 // 解析INFO命令返回的内存信息
 func parseMemoryInfo(info string) string {
 	for _, line := range splitInfo(info) {
@@ -389,6 +852,115 @@ func isMemoryLine(line string) bool {
 	return len(line) > 12 && line[:12] == "used_memory:"
 }
 
+// Cache methods, commands are sent with a background context.
+
+func (c *RedisCache) Set(key string, val interface{}, expire int64) error {
+	return c.SetCtx(context.Background(), key, val, expire)
+}
+
+func (c *RedisCache) SetEx(key string, val interface{}, expire time.Duration) error {
+	return c.SetExCtx(context.Background(), key, val, expire)
+}
+
+func (c *RedisCache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Del(key string) error {
+	return c.DelCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Incr(key string) error {
+	return c.IncrCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Decr(key string) error {
+	return c.DecrCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Exists(key string) bool {
+	return c.ExistsCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Flush() error {
+	return c.FlushCtx(context.Background())
+}
+
+func (c *RedisCache) HMSet(key string, data interface{}) error {
+	return c.HMSetCtx(context.Background(), key, data)
+}
+
+func (c *RedisCache) HMGet(key string, fields []string) (res map[string]string, err error) {
+	return c.HMGetCtx(context.Background(), key, fields)
+}
+
+func (c *RedisCache) HGet(key, field string) (data string, err error) {
+	return c.HGetCtx(context.Background(), key, field)
+}
+
+func (c *RedisCache) HSet(key string, data interface{}) error {
+	return c.HSetCtx(context.Background(), key, data)
+}
+
+func (c *RedisCache) HDel(key, field string) (err error) {
+	return c.HDelCtx(context.Background(), key, field)
+}
+
+func (c *RedisCache) HGetAll(key string) (data map[string]string, err error) {
+	return c.HGetAllCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Expire(key string, expire time.Duration) error {
+	return c.ExpireCtx(context.Background(), key, expire)
+}
+
+func (c *RedisCache) Clear(key string) (err error) {
+	return c.ClearCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Size(bucket string) string {
+	return c.SizeCtx(context.Background(), bucket)
+}
+
+func (c *RedisCache) TTL(key string) time.Duration {
+	return c.TTLCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Type(key string) string {
+	return c.TypeCtx(context.Background(), key)
+}
+
+func (c *RedisCache) Search(bucket string) []string {
+	return c.SearchCtx(context.Background(), bucket)
+}
+
+func (c *RedisCache) Scan(prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	return c.ScanCtx(context.Background(), prefix, cursor, limit)
+}
+
 func init() {
-	Register("redis", &RedisCache{})
+	RegisterFactory("redis", func(opt Options) (Cache, error) {
+		c := NewRedisCache()
+		if err := c.StartAndGC(opt); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// redisError translates go-redis errors of key into the shared sentinels.
+func redisError(key string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, redis.Nil):
+		return errNotFound(key)
+	case errors.Is(err, redis.ErrClosed):
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	case strings.HasPrefix(err.Error(), "WRONGTYPE"):
+		return fmt.Errorf("%w: key '%s': %v", ErrTypeMismatch, key, err)
+	case strings.Contains(err.Error(), "not an integer"):
+		return fmt.Errorf("%w: key '%s': %v", ErrNotInteger, key, err)
+	}
+	return err
 }
@@ -0,0 +1,410 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process stand-in for a redis server speaking enough of
+// RESP2 for the redis adapter: strings, counters, hashes, expirations,
+// transactions, SCAN, UNLINK and pub/sub. WATCH is accepted but does not
+// abort transactions. Tests needing a real server use CACHE_TEST_REDIS_ADDR instead.
+type fakeRedis struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	keys    map[string]*fakeEntry
+	subs    map[string][]*fakeConn
+	cursors []string // last key returned by each SCAN cursor, stable across deletes
+	unlinks int      // number of UNLINK commands received
+	// scanExtra is added to the COUNT of SCAN, redis treating it as a hint.
+	scanExtra int
+}
+
+type fakeEntry struct {
+	str    string
+	hash   map[string]string
+	expire time.Time
+}
+
+type fakeConn struct {
+	net.Conn
+	mu    sync.Mutex
+	queue [][]string // commands queued since MULTI, nil outside a transaction
+}
+
+func (c *fakeConn) write(reply string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	io.WriteString(c.Conn, reply)
+}
+
+// newFakeRedis starts a server stopped at the end of the test.
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{ln: ln, keys: map[string]*fakeEntry{}, subs: map[string][]*fakeConn{}}
+	var conns sync.Map
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c := &fakeConn{Conn: conn}
+			conns.Store(c, nil)
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		conns.Range(func(c, _ interface{}) bool {
+			c.(*fakeConn).Close()
+			return true
+		})
+	})
+	return s
+}
+
+// Addr returns the address to configure the adapter with.
+func (s *fakeRedis) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// raw returns the stored string, or the stored hash fields and values joined
+// by spaces, of key.
+func (s *fakeRedis) raw(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(key)
+	if e == nil {
+		return ""
+	}
+	if e.hash == nil {
+		return e.str
+	}
+	var parts []string
+	for k, v := range e.hash {
+		parts = append(parts, k, v)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *fakeRedis) serve(c *fakeConn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		args, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "MULTI":
+			c.queue = [][]string{}
+			c.write("+OK\r\n")
+		case cmd == "EXEC":
+			reply := "*" + strconv.Itoa(len(c.queue)) + "\r\n"
+			for _, args := range c.queue {
+				reply += s.exec(c, strings.ToUpper(args[0]), args[1:])
+			}
+			c.queue = nil
+			c.write(reply)
+		case c.queue != nil:
+			c.queue = append(c.queue, args)
+			c.write("+QUEUED\r\n")
+		default:
+			c.write(s.exec(c, cmd, args[1:]))
+		}
+	}
+}
+
+// readRESP reads a command sent as an array of bulk strings.
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulkRESP(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func intRESP(n int) string {
+	return ":" + strconv.Itoa(n) + "\r\n"
+}
+
+func arrayRESP(items []string) string {
+	out := "*" + strconv.Itoa(len(items)) + "\r\n"
+	for _, item := range items {
+		out += bulkRESP(item)
+	}
+	return out
+}
+
+// get returns the live entry of key, the caller must hold the lock.
+func (s *fakeRedis) get(key string) *fakeEntry {
+	e, ok := s.keys[key]
+	if ok && !e.expire.IsZero() && !time.Now().Before(e.expire) {
+		delete(s.keys, key)
+		return nil
+	}
+	return e
+}
+
+func (s *fakeRedis) exec(c *fakeConn, cmd string, args []string) string {
+	if cmd == "PUBLISH" {
+		s.mu.Lock()
+		subs := s.subs[args[0]]
+		s.mu.Unlock()
+		for _, sub := range subs {
+			sub.write(arrayRESP([]string{"message", args[0], args[1]}))
+		}
+		return intRESP(len(subs))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT", "WATCH", "UNWATCH":
+		return "+OK\r\n"
+	case "SUBSCRIBE":
+		s.subs[args[0]] = append(s.subs[args[0]], c)
+		return "*3\r\n" + bulkRESP("subscribe") + bulkRESP(args[0]) + intRESP(1)
+	case "SET":
+		e := &fakeEntry{str: args[1]}
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX", "PX":
+				n, _ := strconv.Atoi(args[i+1])
+				unit := time.Second
+				if strings.ToUpper(args[i]) == "PX" {
+					unit = time.Millisecond
+				}
+				e.expire = time.Now().Add(time.Duration(n) * unit)
+				i++
+			case "KEEPTTL":
+				if old := s.get(args[0]); old != nil {
+					e.expire = old.expire
+				}
+			}
+		}
+		s.keys[args[0]] = e
+		return "+OK\r\n"
+	case "GET":
+		e := s.get(args[0])
+		if e == nil {
+			return "$-1\r\n"
+		} else if e.hash != nil {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return bulkRESP(e.str)
+	case "DEL", "UNLINK", "EXISTS":
+		n := 0
+		for _, key := range args {
+			if s.get(key) != nil {
+				n++
+				if cmd != "EXISTS" {
+					delete(s.keys, key)
+				}
+			}
+		}
+		if cmd == "UNLINK" {
+			s.unlinks++
+		}
+		return intRESP(n)
+	case "INCR", "DECR":
+		e := s.get(args[0])
+		if e == nil {
+			e = &fakeEntry{str: "0"}
+			s.keys[args[0]] = e
+		} else if e.hash != nil {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		n, err := strconv.Atoi(e.str)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		if cmd == "INCR" {
+			n++
+		} else {
+			n--
+		}
+		e.str = strconv.Itoa(n)
+		return intRESP(n)
+	case "EXPIRE", "PEXPIRE":
+		e := s.get(args[0])
+		if e == nil {
+			return intRESP(0)
+		}
+		n, _ := strconv.Atoi(args[1])
+		unit := time.Second
+		if cmd == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		e.expire = time.Now().Add(time.Duration(n) * unit)
+		return intRESP(1)
+	case "TTL", "PTTL":
+		e := s.get(args[0])
+		switch {
+		case e == nil:
+			return intRESP(-2)
+		case e.expire.IsZero():
+			return intRESP(-1)
+		case cmd == "TTL":
+			return intRESP(int((time.Until(e.expire) + time.Second - 1) / time.Second))
+		default:
+			return intRESP(int(time.Until(e.expire) / time.Millisecond))
+		}
+	case "TYPE":
+		e := s.get(args[0])
+		switch {
+		case e == nil:
+			return "+none\r\n"
+		case e.hash != nil:
+			return "+hash\r\n"
+		default:
+			return "+string\r\n"
+		}
+	case "SCAN":
+		return s.scan(args)
+	case "HSET", "HMSET":
+		e := s.get(args[0])
+		if e == nil {
+			e = &fakeEntry{hash: map[string]string{}}
+			s.keys[args[0]] = e
+		} else if e.hash == nil {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		for i := 1; i+1 < len(args); i += 2 {
+			e.hash[args[i]] = args[i+1]
+		}
+		if cmd == "HMSET" {
+			return "+OK\r\n"
+		}
+		return intRESP((len(args) - 1) / 2)
+	case "HGET", "HMGET", "HGETALL", "HKEYS", "HDEL":
+		e := s.get(args[0])
+		if e != nil && e.hash == nil {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		var hash map[string]string
+		if e != nil {
+			hash = e.hash
+		}
+		switch cmd {
+		case "HGET":
+			if v, ok := hash[args[1]]; ok {
+				return bulkRESP(v)
+			}
+			return "$-1\r\n"
+		case "HMGET":
+			out := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+			for _, field := range args[1:] {
+				if v, ok := hash[field]; ok {
+					out += bulkRESP(v)
+				} else {
+					out += "$-1\r\n"
+				}
+			}
+			return out
+		case "HDEL":
+			n := 0
+			for _, field := range args[1:] {
+				if _, ok := hash[field]; ok {
+					delete(hash, field)
+					n++
+				}
+			}
+			if e != nil && len(hash) == 0 {
+				delete(s.keys, args[0])
+			}
+			return intRESP(n)
+		}
+		var items []string
+		for k, v := range hash {
+			items = append(items, k)
+			if cmd == "HGETALL" {
+				items = append(items, v)
+			}
+		}
+		return arrayRESP(items)
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+// scan walks the keys in lexical order, count keys per call.
+func (s *fakeRedis) scan(args []string) string {
+	cursor, _ := strconv.Atoi(args[0])
+	match, count := "*", 10
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "COUNT":
+			count, _ = strconv.Atoi(args[i+1])
+		}
+	}
+	count += s.scanExtra
+	var all []string
+	for key := range s.keys {
+		if s.get(key) != nil {
+			all = append(all, key)
+		}
+	}
+	sort.Strings(all)
+	if cursor > 0 {
+		after := s.cursors[cursor-1]
+		all = all[sort.SearchStrings(all, after+"\x00"):]
+	}
+	next := 0
+	if len(all) > count {
+		all = all[:count]
+		s.cursors = append(s.cursors, all[count-1])
+		next = len(s.cursors)
+	}
+	var keys []string
+	for _, key := range all {
+		if ok, _ := path.Match(match, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return "*2\r\n" + bulkRESP(strconv.Itoa(next)) + arrayRESP(keys)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRedisInvalidOptions(t *testing.T) {
+	for _, config := range []string{
+		"db=one",
+		"db=-1",
+		"pool_size=",
+		"pool_size=0",
+		"idle_timeout=3m",
+		"scan_count=0",
+		"read_timeout=3",
+	} {
+		c := NewRedisCache()
+		err := c.StartAndGC(Options{AdapterConfig: "addr=127.0.0.1:1," + config})
+		name := config[:strings.Index(config, "=")]
+		if err == nil || !strings.Contains(err.Error(), "cache/redis:") || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: err = %v, want an error naming the option", config, err)
+		}
+	}
+}
+
+func TestRedisUnreachableClosesClient(t *testing.T) {
+	// Find a port nothing listens on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := NewRedisCache()
+	if err := c.StartAndGC(Options{AdapterConfig: "addr=" + addr + ",db=2,pool_size=4,idle_timeout=60"}); err == nil {
+		t.Fatal("StartAndGC succeeded without a server")
+	}
+	if err := c.Set("key", "value", 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Set = %v, want ErrClosed", err)
+	}
+	if _, err := NewCacher("redis", Options{AdapterConfig: "addr=" + addr}); err == nil {
+		t.Error("NewCacher succeeded without a server")
+	}
+}
+
+func TestRedisClearLiteralPrefix(t *testing.T) {
+	s := newFakeRedis(t)
+	c := startTestCache(t, NewRedisCache(), Options{AdapterConfig: "addr=" + s.Addr() + ",prefix=[app]:,scan_count=2", OccupyMode: true})
+	for _, key := range []string{"a*b_1", "a*b_2", "axb_1", "a?b_1", "ab_1"} {
+		if err := c.Set(key, "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Clear("a*b_"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{"a*b_1": false, "a*b_2": false, "axb_1": true, "a?b_1": true, "ab_1": true} {
+		if got := c.Exists(key); got != want {
+			t.Errorf("Exists(%s) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRedisScanLimit(t *testing.T) {
+	s := newFakeRedis(t)
+	s.scanExtra = 3
+	c := startTestCache(t, NewRedisCache(), Options{AdapterConfig: "addr=" + s.Addr() + ",prefix=app:", OccupyMode: true})
+	want := make(map[string]bool)
+	for i := 0; i < 25; i++ {
+		key := "user_" + strconv.Itoa(i)
+		want[key] = true
+		if err := c.Set(key, i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Set("post_1", 1, 0)
+
+	for _, limit := range []int{1, 4, 7, 25, 100} {
+		seen := make(map[string]bool)
+		cursor := ""
+		for pages := 0; ; pages++ {
+			keys, next, err := c.Scan("user_", cursor, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) > limit {
+				t.Errorf("limit %d: page of %d keys", limit, len(keys))
+			}
+			for _, info := range keys {
+				if !want[info.Key] || seen[info.Key] || info.Type != "string" {
+					t.Errorf("limit %d: unexpected %+v", limit, info)
+				}
+				seen[info.Key] = true
+			}
+			if next == "" {
+				break
+			}
+			if pages > 50 {
+				t.Fatalf("limit %d: cursor never ends", limit)
+			}
+			cursor = next
+		}
+		if len(seen) != len(want) {
+			t.Errorf("limit %d: listed %d keys, want %d", limit, len(seen), len(want))
+		}
+	}
+
+	if _, _, err := c.Scan("", "12:not base64", 10); err == nil {
+		t.Error("Scan accepted an invalid cursor")
+	}
+}
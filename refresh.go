@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// refreshItem is a value stored by RefreshCache with the time it turns stale.
+type refreshItem[T any] struct {
+	Val     T     `json:"val"`
+	Created int64 `json:"created"` // unix nanoseconds
+	Stale   int64 `json:"stale"`   // unix nanoseconds, the value is refreshed from then on
+}
+
+// RefreshCache serves values of type T loaded by a registered loader and
+// refreshes them before they expire. A value is fresh for the soft TTL, then
+// stale until the hard TTL: Get returns a stale value right away and reloads
+// it in the background, once per key at a time. Past the hard TTL the value
+// is gone and Get blocks on the loader, shared by concurrent callers.
+type RefreshCache[T any] struct {
+	cache   *TypedCache[refreshItem[T]]
+	loader  func(ctx context.Context, key string) (T, error)
+	soft    time.Duration
+	hard    time.Duration
+	flights flightGroup
+}
+
+// NewRefreshCache returns a RefreshCache storing its values in c. A hard TTL
+// of 0 or less keeps values until they are deleted, they are still refreshed
+// after the soft TTL.
+func NewRefreshCache[T any](c Cache, soft, hard time.Duration, loader func(ctx context.Context, key string) (T, error)) (*RefreshCache[T], error) {
+	if loader == nil {
+		return nil, errors.New("cache: refresh loader is nil")
+	}
+	if soft <= 0 || (hard > 0 && soft > hard) {
+		return nil, errors.New("cache: soft TTL must be positive and not exceed the hard TTL")
+	}
+	return &RefreshCache[T]{cache: NewTypedCache[refreshItem[T]](c), loader: loader, soft: soft, hard: hard}, nil
+}
+
+// Get returns the value of key, see RefreshCache.
+func (r *RefreshCache[T]) Get(key string) (T, error) {
+	return r.GetCtx(context.Background(), key)
+}
+
+// GetCtx returns the value of key, see RefreshCache. Loads are not bound to
+// ctx, they keep its values and time out after a minute, ctx only bounds how
+// long GetCtx waits for them.
+func (r *RefreshCache[T]) GetCtx(ctx context.Context, key string) (T, error) {
+	item, err := r.cache.GetCtx(ctx, key)
+	if err == nil {
+		if time.Now().UnixNano() >= item.Stale {
+			r.flights.goDo(ctx, key, func(ctx context.Context) (interface{}, error) {
+				val, err := r.load(ctx, key)
+				if err != nil {
+					log.Printf("cache: refresh '%s': %v", key, err)
+				}
+				return val, err
+			})
+		}
+		return item.Val, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return item.Val, err
+	}
+
+	val, err := r.flights.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return r.load(ctx, key)
+	})
+	res, _ := val.(T)
+	return res, err
+}
+
+// Refresh reloads the value of key now.
+func (r *RefreshCache[T]) Refresh(ctx context.Context, key string) (T, error) {
+	return r.load(ctx, key)
+}
+
+// Del deletes the value of key, the next Get loads it again.
+func (r *RefreshCache[T]) Del(key string) error {
+	return r.cache.Del(key)
+}
+
+// load calls the loader and stores its value.
+func (r *RefreshCache[T]) load(ctx context.Context, key string) (T, error) {
+	val, err := r.loader(ctx, key)
+	if err != nil {
+		return val, err
+	}
+	now := time.Now()
+	item := refreshItem[T]{Val: val, Created: now.UnixNano(), Stale: now.Add(r.soft).UnixNano()}
+	return val, r.cache.SetExCtx(ctx, key, item, r.hard)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingLoader returns "v1", "v2"... and counts its calls, the calls after
+// the first wait for block to be closed.
+func countingLoader(calls *atomic.Int32, block chan struct{}) func(context.Context, string) (string, error) {
+	return func(ctx context.Context, key string) (string, error) {
+		n := calls.Add(1)
+		if n > 1 {
+			select {
+			case <-block:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		return "v" + strconv.Itoa(int(n)), nil
+	}
+}
+
+// getWithin fails unless r.Get returns within d.
+func getWithin(t *testing.T, r *RefreshCache[string], key string, d time.Duration) string {
+	t.Helper()
+	type result struct {
+		val string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, err := r.Get(key)
+		done <- result{val, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		return res.val
+	case <-time.After(d):
+		t.Fatalf("Get(%s) blocked", key)
+		return ""
+	}
+}
+
+func TestRefreshCacheStale(t *testing.T) {
+	var calls atomic.Int32
+	block := make(chan struct{})
+	r, err := NewRefreshCache(startTestCache(t, NewMemoryCache(), Options{}), 20*time.Millisecond, time.Minute, countingLoader(&calls, block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := getWithin(t, r, "key", time.Second); val != "v1" {
+		t.Fatalf("Get = %q, want v1", val)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Stale reads return right away while a single reload is running.
+	for i := 0; i < 10; i++ {
+		if val := getWithin(t, r, "key", 100*time.Millisecond); val != "v1" {
+			t.Fatalf("stale Get = %q, want v1", val)
+		}
+	}
+	eventually(t, "stale Get did not start a reload", func() bool {
+		return calls.Load() == 2
+	})
+	close(block)
+	eventually(t, "reloaded value not served", func() bool {
+		return getWithin(t, r, "key", time.Second) == "v2"
+	})
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
+
+func TestRefreshCacheExpired(t *testing.T) {
+	var calls atomic.Int32
+	block := make(chan struct{})
+	r, err := NewRefreshCache(startTestCache(t, NewMemoryCache(), Options{}), 20*time.Millisecond, 50*time.Millisecond, countingLoader(&calls, block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := getWithin(t, r, "key", time.Second); val != "v1" {
+		t.Fatalf("Get = %q, want v1", val)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// Past the hard TTL every caller waits for one shared load.
+	var wg sync.WaitGroup
+	vals := make([]string, 5)
+	for i := range vals {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vals[i], _ = r.Get("key")
+		}(i)
+	}
+	eventually(t, "expired Get did not load", func() bool {
+		return calls.Load() == 2
+	})
+	time.Sleep(20 * time.Millisecond)
+	for i, val := range vals {
+		if len(val) > 0 {
+			t.Fatalf("caller %d got %q before the load returned", i, val)
+		}
+	}
+	close(block)
+	wg.Wait()
+	for i, val := range vals {
+		if val != "v2" {
+			t.Errorf("caller %d got %q, want v2", i, val)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+}
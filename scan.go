@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultScanLimit is the page size of Scan when the limit is 0 or less.
+const defaultScanLimit = 100
+
+// KeyInfo describes a key listed by Scan.
+type KeyInfo struct {
+	Key  string        // the key as passed to Set, without the adapter prefix
+	TTL  time.Duration // remaining time to live, -1 without expiration
+	Type string        // "string" or "hash"
+}
+
+// scanLimit returns the page size for limit.
+func scanLimit(limit int) int {
+	if limit <= 0 {
+		return defaultScanLimit
+	}
+	return limit
+}
+
+const (
+	// scanSnapshotTTL is the age past which a snapshot is taken again.
+	scanSnapshotTTL = time.Minute
+	// maxScanSnapshots is the number of prefixes snapshots are kept for.
+	maxScanSnapshots = 16
+)
+
+// scanSnapshots keeps the sorted keys of the prefixes recently scanned, so a
+// walk over N keys collects and sorts them once rather than for every page.
+// Keys added after the snapshot was taken are not listed, keys removed since
+// are left out by the adapter.
+type scanSnapshots struct {
+	lock  sync.Mutex
+	snaps map[string]*scanSnapshot
+}
+
+type scanSnapshot struct {
+	keys  []string // sorted
+	taken time.Time
+}
+
+// after returns the keys of the snapshot of prefix sorting after cursor. A
+// snapshot is taken with collect, returning the keys starting with prefix,
+// when a walk starts with an empty cursor or when there is no recent one.
+func (s *scanSnapshots) after(prefix, cursor string, collect func() ([]string, error)) ([]string, error) {
+	s.lock.Lock()
+	snap := s.snaps[prefix]
+	s.lock.Unlock()
+	if snap == nil || len(cursor) == 0 || time.Since(snap.taken) > scanSnapshotTTL {
+		keys, err := collect()
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(keys)
+		snap = &scanSnapshot{keys: keys, taken: time.Now()}
+		s.store(prefix, snap)
+	}
+	return snap.keys[sort.Search(len(snap.keys), func(i int) bool {
+		return snap.keys[i] > cursor
+	}):], nil
+}
+
+// store keeps snap for prefix, dropping the oldest snapshot when full.
+func (s *scanSnapshots) store(prefix string, snap *scanSnapshot) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.snaps == nil {
+		s.snaps = make(map[string]*scanSnapshot)
+	}
+	if _, ok := s.snaps[prefix]; !ok && len(s.snaps) >= maxScanSnapshots {
+		var oldest string
+		for p, other := range s.snaps {
+			if old, ok := s.snaps[oldest]; !ok || other.taken.Before(old.taken) {
+				oldest = p
+			}
+		}
+		delete(s.snaps, oldest)
+	}
+	s.snaps[prefix] = snap
+}
+
+// pageKeys returns the infos of the first limit keys info finds, with the
+// cursor of the next page or "" when there are no more keys. info reports
+// false for keys removed since the snapshot.
+func pageKeys(keys []string, limit int, info func(key string) (KeyInfo, bool)) (page []KeyInfo, next string) {
+	page = make([]KeyInfo, 0, limit)
+	for _, key := range keys {
+		if len(page) == limit {
+			return page, page[limit-1].Key
+		}
+		if ki, ok := info(key); ok {
+			page = append(page, ki)
+		}
+	}
+	return page, ""
+}
+
+// Iterator walks the keys of a cache starting with a prefix, fetching them a
+// page at a time with Scan:
+//
+//	it := cache.NewIterator(c, "user_", 0)
+//	for it.Next(ctx) {
+//		fmt.Println(it.Info().Key)
+//	}
+//	err := it.Err()
+//
+// Keys changed during the walk may be missed or, on redis, listed twice.
+type Iterator struct {
+	c      Cache
+	prefix string
+	limit  int
+	cursor string
+	page   []KeyInfo
+	pos    int
+	done   bool
+	err    error
+}
+
+// NewIterator returns an iterator over the keys of c starting with prefix,
+// fetching limit keys per page, 100 if it is 0 or less.
+func NewIterator(c Cache, prefix string, limit int) *Iterator {
+	return &Iterator{c: c, prefix: prefix, limit: limit, pos: -1}
+}
+
+// Next advances to the next key, it returns false when the keys are exhausted
+// or an error occurred.
+func (it *Iterator) Next(ctx context.Context) bool {
+	it.pos++
+	for it.pos >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.page, it.cursor, it.err = scanCtx(ctx, it.c, it.prefix, it.cursor, it.limit)
+		it.pos = 0
+		it.done = len(it.cursor) == 0
+	}
+	return it.err == nil
+}
+
+// Info returns the current key.
+func (it *Iterator) Info() KeyInfo {
+	if it.pos < 0 || it.pos >= len(it.page) {
+		return KeyInfo{}
+	}
+	return it.page[it.pos]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func scanCtx(ctx context.Context, c Cache, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	if cc, ok := c.(CacheContext); ok {
+		return cc.ScanCtx(ctx, prefix, cursor, limit)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return c.Scan(prefix, cursor, limit)
+}
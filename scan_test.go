@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestScanSnapshots(t *testing.T) {
+	var s scanSnapshots
+	collects := 0
+	collect := func() ([]string, error) {
+		collects++
+		return []string{"k3", "k1", "k2"}, nil
+	}
+	for _, tc := range []struct {
+		cursor   string
+		want     []string
+		collects int
+	}{
+		{"", []string{"k1", "k2", "k3"}, 1},
+		// The pages after the first are served from the snapshot.
+		{"k1", []string{"k2", "k3"}, 1},
+		{"k2", []string{"k3"}, 1},
+		{"k3", []string{}, 1},
+		// A new walk takes a new snapshot.
+		{"", []string{"k1", "k2", "k3"}, 2},
+	} {
+		got, err := s.after("k", tc.cursor, collect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) || collects != tc.collects {
+			t.Errorf("after(%q) = %q with %d collects, want %q with %d", tc.cursor, got, collects, tc.want, tc.collects)
+		}
+	}
+
+	// Snapshots are kept for a bounded number of prefixes.
+	for i := 0; i < 2*maxScanSnapshots; i++ {
+		s.after(strconv.Itoa(i), "", collect)
+	}
+	if n := len(s.snaps); n != maxScanSnapshots {
+		t.Errorf("kept %d snapshots, want %d", n, maxScanSnapshots)
+	}
+	if _, ok := s.snaps[strconv.Itoa(2*maxScanSnapshots-1)]; !ok {
+		t.Error("dropped the latest snapshot")
+	}
+}
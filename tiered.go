@@ -0,0 +1,477 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// tier is a cache usable as a level of a TieredCache.
+type tier interface {
+	Cache
+	CacheContext
+}
+
+// Invalidator is implemented by shared caches announcing the changes made
+// through other instances, like RedisCache with an invalidation channel.
+type Invalidator interface {
+	Subscribe(fn func(Invalidation)) (unsubscribe func() error, err error)
+}
+
+// TieredCache represents a multi-tier cache adapter implementation. It puts
+// a local cache, L1, in front of a shared one like redis or badger, L2.
+// Reads go through L1 to L2 and fill L1 on the way back, writes go to L2 and
+// drop the key from L1, so L1 only holds values as L2 returns them and Get
+// returns the same shape whichever tier serves it. Deletes, Clear and Flush
+// reach every tier. When L2 is an Invalidator, the changes made by other
+// instances are evicted from L1 too.
+type TieredCache struct {
+	l1          tier
+	l2          tier
+	l1TTL       time.Duration
+	unsubscribe func() error
+
+	// Drops from L1 are counted per key stripe, and for every key by Clear
+	// and Flush, so a value read from L2 before a drop does not fill L1
+	// after it, see fill. Keys of different stripes drop and fill in
+	// parallel, lock is only held for writing by Clear and Flush.
+	lock    sync.RWMutex
+	flush   uint64
+	stripes [tieredStripes]tieredStripe
+}
+
+// tieredStripes is the number of drop counters the keys share.
+const tieredStripes = 64
+
+// tieredStripe counts the drops from L1 of the keys it holds.
+type tieredStripe struct {
+	lock  sync.Mutex
+	drops uint64
+}
+
+// stripe returns the stripe of key.
+func (c *TieredCache) stripe(key string) *tieredStripe {
+	return &c.stripes[shardIndex(key, tieredStripes-1)]
+}
+
+// NewTieredCache creates and returns a tiered cache over l1 and l2, which
+// must implement CacheContext like every adapter of this package. Values live
+// in L1 for at most l1TTL, 0 or less keeps them as long as in L2.
+func NewTieredCache(l1, l2 Cache, l1TTL time.Duration) (*TieredCache, error) {
+	c := &TieredCache{}
+	if err := c.init(l1, l2, l1TTL); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// init sets the tiers of c and subscribes to the invalidations of l2.
+func (c *TieredCache) init(l1, l2 Cache, l1TTL time.Duration) error {
+	t1, ok1 := l1.(tier)
+	t2, ok2 := l2.(tier)
+	if !ok1 || !ok2 {
+		return errors.New("cache/tiered: tiers must implement CacheContext")
+	}
+	c.l1, c.l2, c.l1TTL = t1, t2, l1TTL
+	if inv, ok := l2.(Invalidator); ok {
+		unsubscribe, err := inv.Subscribe(c.evict)
+		if err != nil {
+			return fmt.Errorf("cache/tiered: subscribe to invalidations: %w", err)
+		}
+		c.unsubscribe = unsubscribe
+	}
+	return nil
+}
+
+// evict drops from L1 the keys another instance changed in L2.
+func (c *TieredCache) evict(inv Invalidation) {
+	ctx := context.Background()
+	switch inv.Op {
+	case InvalidateKey:
+		c.drop(ctx, inv.Key)
+	case InvalidateBucket:
+		c.dropAll(func() error { return c.l1.ClearCtx(ctx, inv.Key) })
+	case InvalidateFlush:
+		c.dropAll(func() error { return c.l1.FlushCtx(ctx) })
+	}
+}
+
+// version returns the number of times key was dropped from L1.
+func (c *TieredCache) version(key string) uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	s := c.stripe(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return c.flush + s.drops
+}
+
+// drop deletes key from L1.
+func (c *TieredCache) drop(ctx context.Context, key string) error {
+	s := c.stripe(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.drops++
+	return c.l1.DelCtx(ctx, key)
+}
+
+// dropAll runs fn, deleting any number of keys from L1.
+func (c *TieredCache) dropAll(fn func() error) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.flush++
+	return fn()
+}
+
+// fill runs fn, storing key in L1, unless key was dropped since version was
+// taken: the value read from L2 meanwhile may predate the change.
+func (c *TieredCache) fill(key string, version uint64, fn func()) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	s := c.stripe(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c.flush+s.drops == version {
+		fn()
+	}
+}
+
+// L1 returns the local tier.
+func (c *TieredCache) L1() Cache {
+	return c.l1
+}
+
+// L2 returns the shared tier.
+func (c *TieredCache) L2() Cache {
+	return c.l2
+}
+
+// ttl1 returns the L1 expiration of a value expiring in L2 after expire.
+func (c *TieredCache) ttl1(expire time.Duration) time.Duration {
+	if c.l1TTL > 0 && (expire <= 0 || c.l1TTL < expire) {
+		return c.l1TTL
+	}
+	return expire
+}
+
+// SetCtx puts value into cache with key and expire time in seconds.
+// If expired is 0, it lives forever.
+func (c *TieredCache) SetCtx(ctx context.Context, key string, val interface{}, expire int64) error {
+	return c.SetExCtx(ctx, key, val, time.Duration(expire)*time.Second)
+}
+
+// SetExCtx puts value into L2 with key and expire duration and drops it
+// from L1, the next Get fills L1 with the value as L2 returns it.
+func (c *TieredCache) SetExCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	return c.invalidate(ctx, key, c.l2.SetExCtx(ctx, key, val, expire))
+}
+
+// GetCtx gets cached value by given key from L1, or from L2 and keeps it in L1.
+func (c *TieredCache) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	val, err := c.l1.GetCtx(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return val, err
+	}
+	version := c.version(key)
+	if val, err = c.l2.GetCtx(ctx, key); err != nil {
+		return val, err
+	}
+	if ttl := c.l2.TTLCtx(ctx, key); ttl != -2 {
+		c.fill(key, version, func() {
+			c.l1.SetExCtx(ctx, key, val, c.ttl1(ttl))
+		})
+	}
+	return val, nil
+}
+
+// DelCtx deletes cached value by given key from every tier.
+func (c *TieredCache) DelCtx(ctx context.Context, key string) error {
+	return errors.Join(c.l2.DelCtx(ctx, key), c.drop(ctx, key))
+}
+
+// IncrCtx increases cached int-type value by given key as a counter.
+func (c *TieredCache) IncrCtx(ctx context.Context, key string) error {
+	return c.invalidate(ctx, key, c.l2.IncrCtx(ctx, key))
+}
+
+// DecrCtx decreases cached int-type value by given key as a counter.
+func (c *TieredCache) DecrCtx(ctx context.Context, key string) error {
+	return c.invalidate(ctx, key, c.l2.DecrCtx(ctx, key))
+}
+
+// invalidate drops key from L1 after a write to L2 returning err.
+func (c *TieredCache) invalidate(ctx context.Context, key string, err error) error {
+	return errors.Join(err, c.drop(ctx, key))
+}
+
+// ExistsCtx returns true if cached value exists.
+func (c *TieredCache) ExistsCtx(ctx context.Context, key string) bool {
+	return c.l1.ExistsCtx(ctx, key) || c.l2.ExistsCtx(ctx, key)
+}
+
+// FlushCtx deletes all cached data of every tier.
+func (c *TieredCache) FlushCtx(ctx context.Context) error {
+	err := c.l2.FlushCtx(ctx)
+	return errors.Join(err, c.dropAll(func() error { return c.l1.FlushCtx(ctx) }))
+}
+
+// StartAndGC creates both tiers from configuration sections, see LoadConfig.
+// AdapterConfig: l1=cache.local,l2=cache.shared,l1_ttl=30s
+// l2 is required, without l1 a memory cache is used.
+func (c *TieredCache) StartAndGC(opt Options) error {
+	cfg, err := ini.Load([]byte(strings.Replace(opt.AdapterConfig, ",", "\n", -1)))
+	if err != nil {
+		return err
+	}
+	var l1, l2 string
+	var l1TTL time.Duration
+	for k, v := range cfg.Section("").KeysHash() {
+		switch k {
+		case "l1":
+			l1 = v
+		case "l2":
+			l2 = v
+		case "l1_ttl":
+			if l1TTL, err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("cache/tiered: invalid l1_ttl '%s'", v)
+			}
+		default:
+			return fmt.Errorf("cache/tiered: unsupported option '%s'", k)
+		}
+	}
+	if len(l2) == 0 {
+		return errors.New("cache/tiered: option 'l2' is required")
+	}
+
+	var local Cache = NewMemoryCache()
+	if len(l1) > 0 {
+		local, err = New(Options{Section: l1})
+	} else {
+		err = local.StartAndGC(Options{Interval: opt.Interval})
+	}
+	if err != nil {
+		return fmt.Errorf("cache/tiered: l1: %w", err)
+	}
+	shared, err := New(Options{Section: l2})
+	if err != nil {
+		local.Close()
+		return fmt.Errorf("cache/tiered: l2: %w", err)
+	}
+	if err := c.init(local, shared, l1TTL); err != nil {
+		local.Close()
+		shared.Close()
+		return err
+	}
+	return nil
+}
+
+// Close stops listening for invalidations and closes every tier.
+func (c *TieredCache) Close() error {
+	if c.l1 == nil {
+		return ErrClosed
+	}
+	var err error
+	if c.unsubscribe != nil {
+		err = c.unsubscribe()
+		c.unsubscribe = nil
+	}
+	return errors.Join(err, c.l2.Close(), c.l1.Close())
+}
+
+// HMSetCtx stores the tagged fields of a struct in a hash of L2.
+func (c *TieredCache) HMSetCtx(ctx context.Context, key string, data interface{}) error {
+	return c.invalidate(ctx, key, c.l2.HMSetCtx(ctx, key, data))
+}
+
+// HMScan copies the fields of a hash into the struct dst points to.
+func (c *TieredCache) HMScan(val map[string]string, dst interface{}) error {
+	return c.l2.HMScan(val, dst)
+}
+
+// HMGetCtx gets several fields of a hash.
+func (c *TieredCache) HMGetCtx(ctx context.Context, key string, fields []string) (map[string]string, error) {
+	data, err := c.l1.HMGetCtx(ctx, key, fields)
+	if errors.Is(err, ErrNotFound) {
+		return c.l2.HMGetCtx(ctx, key, fields)
+	}
+	return data, err
+}
+
+// HGetCtx gets one field of a hash.
+func (c *TieredCache) HGetCtx(ctx context.Context, key, field string) (string, error) {
+	data, err := c.l1.HGetCtx(ctx, key, field)
+	if errors.Is(err, ErrNotFound) {
+		return c.l2.HGetCtx(ctx, key, field)
+	}
+	return data, err
+}
+
+// HSetCtx stores the fields of a map in a hash of L2.
+func (c *TieredCache) HSetCtx(ctx context.Context, key string, data interface{}) error {
+	return c.invalidate(ctx, key, c.l2.HSetCtx(ctx, key, data))
+}
+
+// HDelCtx deletes one field of a hash.
+func (c *TieredCache) HDelCtx(ctx context.Context, key, field string) error {
+	return c.invalidate(ctx, key, c.l2.HDelCtx(ctx, key, field))
+}
+
+// HGetAllCtx gets every field of a hash from L1, or from L2 and keeps it in L1.
+func (c *TieredCache) HGetAllCtx(ctx context.Context, key string) (map[string]string, error) {
+	data, err := c.l1.HGetAllCtx(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return data, err
+	}
+	version := c.version(key)
+	if data, err = c.l2.HGetAllCtx(ctx, key); err != nil {
+		return data, err
+	}
+	if ttl := c.l2.TTLCtx(ctx, key); ttl != -2 {
+		c.fill(key, version, func() {
+			if c.l1.HSetCtx(ctx, key, data) != nil {
+				return
+			}
+			if ttl = c.ttl1(ttl); ttl > 0 {
+				c.l1.ExpireCtx(ctx, key, ttl)
+			}
+		})
+	}
+	return data, nil
+}
+
+// ExpireCtx sets the expiration of key in L2 and drops it from L1.
+func (c *TieredCache) ExpireCtx(ctx context.Context, key string, expire time.Duration) error {
+	return c.invalidate(ctx, key, c.l2.ExpireCtx(ctx, key, expire))
+}
+
+// ClearCtx deletes the keys of bucket from every tier.
+func (c *TieredCache) ClearCtx(ctx context.Context, bucket string) error {
+	err := c.l2.ClearCtx(ctx, bucket)
+	return errors.Join(err, c.dropAll(func() error { return c.l1.ClearCtx(ctx, bucket) }))
+}
+
+// SizeCtx returns the size of bucket in L2.
+func (c *TieredCache) SizeCtx(ctx context.Context, bucket string) string {
+	return c.l2.SizeCtx(ctx, bucket)
+}
+
+// TTLCtx returns the remaining time to live of key in L2.
+func (c *TieredCache) TTLCtx(ctx context.Context, key string) time.Duration {
+	return c.l2.TTLCtx(ctx, key)
+}
+
+// TypeCtx returns the type of the value of key in L2.
+func (c *TieredCache) TypeCtx(ctx context.Context, key string) string {
+	return c.l2.TypeCtx(ctx, key)
+}
+
+// ScanCtx lists the keys of L2, see Cache.Scan.
+func (c *TieredCache) ScanCtx(ctx context.Context, prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	return c.l2.ScanCtx(ctx, prefix, cursor, limit)
+}
+
+// SearchCtx returns the keys of bucket in L2.
+func (c *TieredCache) SearchCtx(ctx context.Context, bucket string) []string {
+	return c.l2.SearchCtx(ctx, bucket)
+}
+
+// Cache methods calling their Ctx variants with a background context.
+
+func (c *TieredCache) Set(key string, val interface{}, expire int64) error {
+	return c.SetCtx(context.Background(), key, val, expire)
+}
+
+func (c *TieredCache) SetEx(key string, val interface{}, expire time.Duration) error {
+	return c.SetExCtx(context.Background(), key, val, expire)
+}
+
+func (c *TieredCache) Get(key string) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Del(key string) error {
+	return c.DelCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Incr(key string) error {
+	return c.IncrCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Decr(key string) error {
+	return c.DecrCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Exists(key string) bool {
+	return c.ExistsCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Flush() error {
+	return c.FlushCtx(context.Background())
+}
+
+func (c *TieredCache) HMSet(key string, data interface{}) error {
+	return c.HMSetCtx(context.Background(), key, data)
+}
+
+func (c *TieredCache) HMGet(key string, fields []string) (map[string]string, error) {
+	return c.HMGetCtx(context.Background(), key, fields)
+}
+
+func (c *TieredCache) HGet(key, field string) (string, error) {
+	return c.HGetCtx(context.Background(), key, field)
+}
+
+func (c *TieredCache) HSet(key string, data interface{}) error {
+	return c.HSetCtx(context.Background(), key, data)
+}
+
+func (c *TieredCache) HDel(key, field string) error {
+	return c.HDelCtx(context.Background(), key, field)
+}
+
+func (c *TieredCache) HGetAll(key string) (map[string]string, error) {
+	return c.HGetAllCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Expire(key string, expire time.Duration) error {
+	return c.ExpireCtx(context.Background(), key, expire)
+}
+
+func (c *TieredCache) Clear(bucket string) error {
+	return c.ClearCtx(context.Background(), bucket)
+}
+
+func (c *TieredCache) Size(bucket string) string {
+	return c.SizeCtx(context.Background(), bucket)
+}
+
+func (c *TieredCache) TTL(key string) time.Duration {
+	return c.TTLCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Type(key string) string {
+	return c.TypeCtx(context.Background(), key)
+}
+
+func (c *TieredCache) Search(bucket string) []string {
+	return c.SearchCtx(context.Background(), bucket)
+}
+
+func (c *TieredCache) Scan(prefix, cursor string, limit int) ([]KeyInfo, string, error) {
+	return c.ScanCtx(context.Background(), prefix, cursor, limit)
+}
+
+func init() {
+	RegisterFactory("tiered", func(opt Options) (Cache, error) {
+		c := &TieredCache{}
+		if err := c.StartAndGC(opt); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestTiered returns a tiered cache with a memory L1 in front of l2.
+func newTestTiered(t *testing.T, l2 Cache, l1TTL time.Duration) *TieredCache {
+	t.Helper()
+	l1 := startTestCache(t, NewMemoryCache(), Options{})
+	c, err := NewTieredCache(l1, l2, l1TTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestTieredValueShape(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	l2 := startTestCache(t, NewFileCache(), Options{AdapterConfig: filepath.Join(t.TempDir(), "cache")})
+	c := newTestTiered(t, l2, time.Minute)
+
+	for _, val := range []interface{}{42, "text", 1.5, true, user{"ann", 30}, map[string]int{"a": 1}} {
+		if err := c.Set("key", val, 60); err != nil {
+			t.Fatal(err)
+		}
+		want, err := l2.Get("key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The first Get is served by L2 and fills L1, the second by L1.
+		for i, tier := range []string{"L2", "L1"} {
+			got, err := c.Get("key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Set(%#v): Get from %s = %#v, want %#v as stored in L2", val, tier, got, want)
+			}
+			if i == 0 && !c.L1().Exists("key") {
+				t.Errorf("Set(%#v): Get did not fill L1", val)
+			}
+		}
+	}
+}
+
+// newTestRedisTier returns a redis cache of s publishing its changes.
+func newTestRedisTier(t *testing.T, s *fakeRedis) *RedisCache {
+	t.Helper()
+	c := NewRedisCache()
+	startTestCache(t, c, Options{AdapterConfig: "addr=" + s.Addr() + ",invalidation_channel=invalidate", OccupyMode: true})
+	return c
+}
+
+// eventually fails unless cond turns true within a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal(what)
+		}
+	}
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	s := newFakeRedis(t)
+	a := newTestTiered(t, newTestRedisTier(t, s), 0)
+	b := newTestTiered(t, newTestRedisTier(t, s), 0)
+
+	// Seed through a client not publishing, whose invalidations could
+	// otherwise arrive after b filled its L1.
+	seed := startTestCache(t, NewRedisCache(), Options{AdapterConfig: "addr=" + s.Addr(), OccupyMode: true})
+	for _, key := range []string{"user_1", "user_2", "post_1"} {
+		if err := seed.Set(key, "v1", 0); err != nil {
+			t.Fatal(err)
+		}
+		if val, err := b.Get(key); err != nil || val != "v1" {
+			t.Fatalf("Get(%s) = %v, %v", key, val, err)
+		}
+	}
+
+	a.Set("user_1", "v2", 0)
+	eventually(t, "write not evicted from the other L1", func() bool {
+		return !b.L1().Exists("user_1")
+	})
+	if val, _ := b.Get("user_1"); val != "v2" {
+		t.Errorf("Get = %v, want v2", val)
+	}
+
+	a.Clear("user_")
+	eventually(t, "Clear not evicted from the other L1", func() bool {
+		return !b.L1().Exists("user_1") && !b.L1().Exists("user_2")
+	})
+	if !b.L1().Exists("post_1") {
+		t.Error("Clear evicted a key of another bucket")
+	}
+
+	a.Flush()
+	eventually(t, "Flush not evicted from the other L1", func() bool {
+		return !b.L1().Exists("post_1")
+	})
+}
+
+// pausedTier holds GetCtx after reading from the cache until resumed.
+type pausedTier struct {
+	*RedisCache
+	read   chan struct{}
+	resume chan struct{}
+}
+
+func (p *pausedTier) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	val, err := p.RedisCache.GetCtx(ctx, key)
+	p.read <- struct{}{}
+	<-p.resume
+	return val, err
+}
+
+func TestTieredStaleFill(t *testing.T) {
+	s := newFakeRedis(t)
+	a := newTestTiered(t, newTestRedisTier(t, s), 0)
+	l2 := &pausedTier{RedisCache: newTestRedisTier(t, s), read: make(chan struct{}), resume: make(chan struct{})}
+	b := newTestTiered(t, l2, 0)
+
+	seed := startTestCache(t, NewRedisCache(), Options{AdapterConfig: "addr=" + s.Addr(), OccupyMode: true})
+	seed.Set("key", "v1", 0)
+	got := make(chan interface{})
+	go func() {
+		val, _ := b.Get("key")
+		got <- val
+	}()
+	<-l2.read
+
+	// The value changes and its invalidation arrives while b holds v1.
+	version := b.version("key")
+	a.Set("key", "v2", 0)
+	eventually(t, "invalidation not received", func() bool {
+		return b.version("key") != version
+	})
+	close(l2.resume)
+
+	if val := <-got; val != "v1" {
+		t.Fatalf("Get = %v, want v1 as read", val)
+	}
+	if b.L1().Exists("key") {
+		t.Fatal("L1 filled with the value read before the invalidation")
+	}
+	go func() { <-l2.read }()
+	if val, _ := b.Get("key"); val != "v2" {
+		t.Errorf("Get = %v, want v2", val)
+	}
+}
+
+// blockedDelTier holds DelCtx of key until released.
+type blockedDelTier struct {
+	*MemoryCache
+	key      string
+	deleting chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockedDelTier) DelCtx(ctx context.Context, key string) error {
+	if key == b.key {
+		b.deleting <- struct{}{}
+		<-b.release
+	}
+	return b.MemoryCache.DelCtx(ctx, key)
+}
+
+func TestTieredStripesInParallel(t *testing.T) {
+	l1 := &blockedDelTier{MemoryCache: NewMemoryCache(), key: "slow", deleting: make(chan struct{}), release: make(chan struct{})}
+	startTestCache(t, l1.MemoryCache, Options{})
+	c, err := NewTieredCache(l1, startTestCache(t, NewMemoryCache(), Options{}), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := "fast"
+	for i := 0; shardIndex(other, tieredStripes-1) == shardIndex("slow", tieredStripes-1); i++ {
+		other = "fast" + strconv.Itoa(i)
+	}
+
+	go c.Del("slow")
+	<-l1.deleting
+	// The keys of other stripes are written and filled while "slow" is
+	// being dropped.
+	done := make(chan error, 1)
+	go func() {
+		if err := c.Set(other, "v", 0); err != nil {
+			done <- err
+			return
+		}
+		_, err := c.Get(other)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("writes to another stripe wait for the drop of slow")
+	}
+	if !l1.Exists(other) {
+		t.Error("Get did not fill L1")
+	}
+	close(l1.release)
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// TypedCache stores values of type T in any Cache. Values are encoded as JSON
+// on Set and decoded back into T on Get, so the result does not depend on how
+// the adapter hands back what it stored.
+type TypedCache[T any] struct {
+	cache Cache
+}
+
+// NewTypedCache returns a TypedCache storing its values in c.
+func NewTypedCache[T any](c Cache) *TypedCache[T] {
+	return &TypedCache[T]{cache: c}
+}
+
+// Cache returns the underlying cache.
+func (t *TypedCache[T]) Cache() Cache {
+	return t.cache
+}
+
+// Set puts value into cache with key and expire time in seconds.
+func (t *TypedCache[T]) Set(key string, val T, expire int64) error {
+	return t.SetCtx(context.Background(), key, val, expire)
+}
+
+// SetEx puts value into cache with key and expire duration.
+func (t *TypedCache[T]) SetEx(key string, val T, expire time.Duration) error {
+	return t.SetExCtx(context.Background(), key, val, expire)
+}
+
+// Get gets cached value by given key.
+func (t *TypedCache[T]) Get(key string) (T, error) {
+	return t.GetCtx(context.Background(), key)
+}
+
+// SetCtx puts value into cache with key and expire time in seconds.
+func (t *TypedCache[T]) SetCtx(ctx context.Context, key string, val T, expire int64) error {
+	return t.SetExCtx(ctx, key, val, time.Duration(expire)*time.Second)
+}
+
+// SetExCtx puts value into cache with key and expire duration.
+func (t *TypedCache[T]) SetExCtx(ctx context.Context, key string, val T, expire time.Duration) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return setExCtx(ctx, t.cache, key, string(data), expire)
+}
+
+// GetCtx gets cached value by given key. A value that does not decode into T
+// returns an ErrTypeMismatch error.
+func (t *TypedCache[T]) GetCtx(ctx context.Context, key string) (res T, err error) {
+	val, err := getCtx(ctx, t.cache, key)
+	if err != nil {
+		return res, err
+	}
+
+	var data []byte
+	switch v := val.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		// Stored without the TypedCache, decode its JSON form.
+		if data, err = json.Marshal(v); err != nil {
+			return res, fmt.Errorf("%w: key '%s': %v", ErrTypeMismatch, key, err)
+		}
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("%w: key '%s': %v", ErrTypeMismatch, key, err)
+	}
+	return res, nil
+}
+
+// Del deletes cached value by given key.
+func (t *TypedCache[T]) Del(key string) error {
+	return t.cache.Del(key)
+}
+
+// Exists returns true if cached value exists.
+func (t *TypedCache[T]) Exists(key string) bool {
+	return t.cache.Exists(key)
+}
@@ -29,18 +29,32 @@ func Incr(val interface{}) (interface{}, error) {
 	switch val.(type) {
 	case int:
 		val = val.(int) + 1
+	case int8:
+		val = val.(int8) + 1
+	case int16:
+		val = val.(int16) + 1
 	case int32:
 		val = val.(int32) + 1
 	case int64:
 		val = val.(int64) + 1
 	case uint:
 		val = val.(uint) + 1
+	case uint8:
+		val = val.(uint8) + 1
+	case uint16:
+		val = val.(uint16) + 1
 	case uint32:
 		val = val.(uint32) + 1
 	case uint64:
 		val = val.(uint64) + 1
+	case string:
+		num, err := strconv.ParseInt(val.(string), 10, 64)
+		if err != nil {
+			return val, ErrNotInteger
+		}
+		val = strconv.FormatInt(num+1, 10)
 	default:
-		return val, errors.New("item value is not int-type")
+		return val, ErrNotInteger
 	}
 	return val, nil
 }
@@ -49,6 +63,10 @@ func Decr(val interface{}) (interface{}, error) {
 	switch val.(type) {
 	case int:
 		val = val.(int) - 1
+	case int8:
+		val = val.(int8) - 1
+	case int16:
+		val = val.(int16) - 1
 	case int32:
 		val = val.(int32) - 1
 	case int64:
@@ -59,6 +77,18 @@ func Decr(val interface{}) (interface{}, error) {
 		} else {
 			return val, errors.New("item value is less than 0")
 		}
+	case uint8:
+		if val.(uint8) > 0 {
+			val = val.(uint8) - 1
+		} else {
+			return val, errors.New("item value is less than 0")
+		}
+	case uint16:
+		if val.(uint16) > 0 {
+			val = val.(uint16) - 1
+		} else {
+			return val, errors.New("item value is less than 0")
+		}
 	case uint32:
 		if val.(uint32) > 0 {
 			val = val.(uint32) - 1
@@ -71,12 +101,38 @@ func Decr(val interface{}) (interface{}, error) {
 		} else {
 			return val, errors.New("item value is less than 0")
 		}
+	case string:
+		num, err := strconv.ParseInt(val.(string), 10, 64)
+		if err != nil {
+			return val, ErrNotInteger
+		}
+		val = strconv.FormatInt(num-1, 10)
 	default:
-		return val, errors.New("item value is not int-type")
+		return val, ErrNotInteger
 	}
 	return val, nil
 }
 
+// expireSeconds converts an expire duration into whole seconds, rounding up
+// so a short positive duration does not turn into "never expires".
+func expireSeconds(expire time.Duration) int64 {
+	if expire <= 0 {
+		return 0
+	}
+	return int64((expire + time.Second - 1) / time.Second)
+}
+
+// unixExpiry returns the unix time in whole seconds at which a value stored
+// at now for expire is gone, rounded up so the value never expires early
+// with adapters checking expirations by the second.
+func unixExpiry(now time.Time, expire time.Duration) int64 {
+	at := now.Add(expire)
+	if at.Nanosecond() > 0 {
+		return at.Unix() + 1
+	}
+	return at.Unix()
+}
+
 func isNotNumber(val interface{}) bool {
 	switch val.(type) {
 	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, string, bool:
@@ -301,3 +357,159 @@ func StringToByte(s string) (b []byte) {
 	bh.Len = sh.Len
 	return b
 }
+
+/**
+ * @desc: 将结构体或map转换为hash字段
+ * @param {interface{}} data
+ * @return {*}
+ */
+func hashFields(data interface{}) (map[string]string, error) {
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	fields := make(map[string]string)
+	switch value.Kind() {
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			fields[ToStr(iter.Key().Interface())] = ToStr(iter.Value().Interface())
+		}
+	case reflect.Struct:
+		types := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			tag, val, child := setValue(types.Field(i), value.Field(i))
+			for _, v := range child {
+				if v["tag"].(string) != "" && v["val"] != nil {
+					fields[v["tag"].(string)] = ToStr(v["val"])
+				}
+			}
+			if tag != "" && val != nil && val != "" {
+				fields[tag] = ToStr(val)
+			}
+		}
+	default:
+		return nil, errors.New("parsing failed")
+	}
+	return fields, nil
+}
+
+// maxSizeDepth bounds how deep sizeOf follows nested values.
+const maxSizeDepth = 16
+
+/**
+ * @desc: 估算值占用的字节数
+ * @param {interface{}} val
+ * @return {*}
+ */
+func sizeOf(val interface{}) int {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case map[string]string:
+		n := 0
+		for k, s := range v {
+			n += len(k) + len(s)
+		}
+		return n
+	}
+	return sizeOfValue(reflect.ValueOf(val), make(map[uintptr]struct{}), 0)
+}
+
+// sizeOfValue estimates the size of value. Pointers already in seen are
+// counted once, so cyclic values terminate, and values nested deeper than
+// maxSizeDepth only count their own size.
+func sizeOfValue(value reflect.Value, seen map[uintptr]struct{}, depth int) int {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.String:
+		return value.Len()
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+	default:
+		return int(value.Type().Size())
+	}
+	if depth >= maxSizeDepth {
+		return int(value.Type().Size())
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			return 0
+		}
+		if value.Kind() != reflect.Slice || value.Len() > 0 {
+			ptr := value.Pointer()
+			if _, ok := seen[ptr]; ok {
+				return 0
+			}
+			seen[ptr] = struct{}{}
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return 0
+		}
+		return sizeOfValue(value.Elem(), seen, depth+1)
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Len()
+		}
+		n := 0
+		for i := 0; i < value.Len(); i++ {
+			n += sizeOfValue(value.Index(i), seen, depth+1)
+		}
+		return n
+	case reflect.Map:
+		n := 0
+		iter := value.MapRange()
+		for iter.Next() {
+			n += sizeOfValue(iter.Key(), seen, depth+1) + sizeOfValue(iter.Value(), seen, depth+1)
+		}
+		return n
+	default: // reflect.Struct
+		n := int(value.Type().Size())
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				n += sizeOfValue(value.Field(i), seen, depth+1)
+			}
+		}
+		return n
+	}
+}
+
+/**
+ * @desc: 解析字节大小，如 256MB、1g、4096
+ * @param {string} str
+ * @return {*}
+ */
+func ParseBytes(str string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(str))
+	s = strings.TrimSuffix(s, "B")
+	unit := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		case 'T':
+			unit = 1 << 40
+		}
+		if unit > 1 {
+			s = s[:n-1]
+		}
+	}
+	num, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || num < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", str)
+	}
+	return int64(num * float64(unit)), nil
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+
+	"github.com/goccy/go-json"
+)
+
+// Tags of the type-preserving value encoding. Scalars are tagged with their
+// reflect.Kind, byte slices and everything else get a tag of their own.
+const (
+	valueBytes byte = 0xfe // raw []byte
+	valueJSON  byte = 0xff // JSON document of a non-scalar value
+)
+
+// encodeValue encodes val into a tagged byte slice that decodeValue turns
+// back into a value of the same kind. Scalars keep their exact kind, byte
+// slices are stored as is and other values are stored as JSON.
+func encodeValue(val interface{}) ([]byte, error) {
+	if b, ok := val.([]byte); ok {
+		return append([]byte{valueBytes}, b...), nil
+	}
+	if val != nil {
+		v := reflect.ValueOf(val)
+		kind := v.Kind()
+		buf := []byte{byte(kind)}
+		switch kind {
+		case reflect.Bool:
+			if v.Bool() {
+				return append(buf, 1), nil
+			}
+			return append(buf, 0), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return binary.AppendVarint(buf, v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return binary.AppendUvarint(buf, v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return binary.BigEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+		case reflect.String:
+			return append(buf, v.String()...), nil
+		}
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{valueJSON}, data...), nil
+}
+
+// decodeValue decodes data produced by encodeValue.
+func decodeValue(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	tag, payload := data[0], data[1:]
+	switch tag {
+	case valueBytes:
+		return append([]byte{}, payload...), nil
+	case valueJSON:
+		var val interface{}
+		err := json.Unmarshal(payload, &val)
+		return val, err
+	}
+
+	switch kind := reflect.Kind(tag); kind {
+	case reflect.Bool:
+		if len(payload) != 1 {
+			return nil, errors.New("malformed bool value")
+		}
+		return payload[0] == 1, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, size := binary.Varint(payload)
+		if size <= 0 {
+			return nil, errors.New("malformed int value")
+		}
+		switch kind {
+		case reflect.Int8:
+			return int8(n), nil
+		case reflect.Int16:
+			return int16(n), nil
+		case reflect.Int32:
+			return int32(n), nil
+		case reflect.Int64:
+			return n, nil
+		}
+		return int(n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, size := binary.Uvarint(payload)
+		if size <= 0 {
+			return nil, errors.New("malformed uint value")
+		}
+		switch kind {
+		case reflect.Uint8:
+			return uint8(n), nil
+		case reflect.Uint16:
+			return uint16(n), nil
+		case reflect.Uint32:
+			return uint32(n), nil
+		case reflect.Uint64:
+			return n, nil
+		}
+		return uint(n), nil
+	case reflect.Float32, reflect.Float64:
+		if len(payload) != 8 {
+			return nil, errors.New("malformed float value")
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(payload))
+		if kind == reflect.Float32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case reflect.String:
+		return string(payload), nil
+	}
+	return nil, errors.New("unknown value encoding")
+}